@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"github.com/shangjin92/ceph-sync/core"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+var bucketConfigCmd = &cobra.Command{
+	Use:   "bucket-config",
+	Short: "sync the configuration of ceph buckets",
+	Long: `ceph-sync bucket-config --config /root/sync.properties --source-type oss \
+      --source-bucket bucket-name \
+      --configs lifecycle,cors --dry-run`,
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(core.SyncBucketConfig())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(bucketConfigCmd)
+
+	bucketConfigCmd.Flags().StringVar(&core.SyncProperties, "config", "/root/sync.properties", "ceph bucket sync config")
+	bucketConfigCmd.Flags().StringVar(&core.SourceType, "source-type", "", "source type, maybe: oss/ceph/s3")
+	bucketConfigCmd.Flags().StringVar(&core.SourceClusterBucket, "source-bucket", "", "bucket name of source cluster, empty for every bucket")
+	bucketConfigCmd.Flags().StringVar(&core.TargetType, "target-type", "ceph", "target type, maybe: ceph/s3/oss")
+	bucketConfigCmd.Flags().StringVar(&core.TargetClusterBucket, "target-bucket", "", "bucket name of target cluster, the source bucket name when empty")
+	bucketConfigCmd.Flags().StringSliceVar(&core.BucketConfigs, "configs", core.BucketConfigNames, "bucket configs to sync, maybe: "+strings.Join(core.BucketConfigNames, "/"))
+	bucketConfigCmd.Flags().BoolVar(&core.DryRun, "dry-run", false, "only print the configs that differ, without changing the target buckets")
+	bucketConfigCmd.Flags().StringVar(&core.StorageClassMap, "storage-class-map", "", "properties file mapping the storage classes of lifecycle transitions to target ones, like IA = STANDARD_IA")
+}
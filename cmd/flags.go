@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"github.com/shangjin92/ceph-sync/core"
+	"github.com/spf13/cobra"
+	"time"
+)
+
+// filterRuleValue appends the values of a filter flag to core.FilterRules, so
+// the rules of --include, --exclude and --filter-from keep their order.
+type filterRuleValue struct {
+	kind     string
+	typeName string
+}
+
+func (value *filterRuleValue) String() string {
+	return ""
+}
+
+func (value *filterRuleValue) Set(pattern string) error {
+	core.FilterRules = append(core.FilterRules, value.kind+" "+pattern)
+	return nil
+}
+
+func (value *filterRuleValue) Type() string {
+	return value.typeName
+}
+
+// addFilterFlags registers the flags selecting the source objects to sync.
+func addFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().Var(&filterRuleValue{kind: "+", typeName: "pattern"}, "include", "sync keys matching this glob or regex:expression, the first matching rule decides")
+	cmd.Flags().Var(&filterRuleValue{kind: "-", typeName: "pattern"}, "exclude", "skip keys matching this glob or regex:expression, the first matching rule decides")
+	cmd.Flags().Var(&filterRuleValue{kind: ".", typeName: "file"}, "filter-from", "read include (+ pattern) and exclude (- pattern) rules from this file")
+	cmd.Flags().StringVar(&core.MinSize, "min-size", "", "skip objects smaller than this size, like 512K or 1.5G")
+	cmd.Flags().StringVar(&core.MaxSize, "max-size", "", "skip objects larger than this size, like 512K or 50G")
+	cmd.Flags().StringVar(&core.ModifiedAfter, "modified-after", "", "skip objects modified before this time, like 2021-08-01, 2021-08-01T12:00:00Z or an age like 7d")
+	cmd.Flags().StringVar(&core.ModifiedBefore, "modified-before", "", "skip objects modified at or after this time, like 2021-08-01, 2021-08-01T12:00:00Z or an age like 7d")
+}
+
+// addRewriteFlags registers the flags rewriting source keys to target keys.
+func addRewriteFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&core.RewriteRules, "rewrite", nil, "rewrite keys with a rule like 's#^logs/(\\d+)/#archive/$1/#', rules apply in order")
+	cmd.Flags().StringVar(&core.KeyTemplate, "key-template", "", "go template building the target key, like '{{.Dir}}/{{lower .Name}}'")
+}
+
+// addBucketFlags registers the flags copying the configuration of source buckets.
+func addBucketFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&core.CopyBucketPolicy, "copy-bucket-policy", false, "copy the policy of the source bucket, with its resources and users mapped to the target")
+	cmd.Flags().BoolVar(&core.CopyBucketCORS, "copy-bucket-cors", false, "copy the CORS rules of the source bucket")
+}
+
+// addVersionFlags registers the flags syncing every version of the source objects.
+func addVersionFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&core.AllVersions, "all-versions", false, "replay every version and delete marker of the source objects onto a versioned target bucket")
+	cmd.Flags().StringVar(&core.VersionMap, "version-map", "version-map.jsonl", "with --all-versions, jsonl file mapping source version IDs to target version IDs, versions listed in it are not replayed again")
+}
+
+// addCheckpointFlags registers the flags saving and resuming the progress of a
+// sync of whole buckets.
+func addCheckpointFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&core.CheckpointFile, "checkpoint", "", "file recording the sync progress, to continue an interrupted sync with --resume")
+	cmd.Flags().BoolVar(&core.Resume, "resume", false, "continue from the last fully completed listing page in the --checkpoint file")
+}
+
+// addTransferFlags registers the flags shared by every command that copies objects.
+func addTransferFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&core.Workers, "workers", 16, "number of objects transferred in parallel")
+	cmd.Flags().StringVar(&core.CompareMode, "compare", core.CompareNone, "skip objects already on the target, maybe: none/size/etag/mtime/checksum")
+	cmd.Flags().BoolVar(&core.DryRun, "dry-run", false, "only print what would be created, overwritten, skipped or deleted")
+	cmd.Flags().StringVar(&core.PlanOutput, "plan-output", "", "with --dry-run, also write the plan as json to this file")
+	cmd.Flags().StringVar(&core.ReportFile, "report", "", "also write the run summary as json to this file")
+	cmd.Flags().StringVar(&core.FailureManifest, "failure-manifest", "failed.jsonl", "jsonl file listing the objects that failed, to copy them again with retry, removed when none failed, empty to disable")
+	cmd.Flags().IntVar(&core.RetryAttempts, "retries", 3, "times a failed request is retried when the error is transient")
+	cmd.Flags().DurationVar(&core.RetryBackoff, "retry-backoff", time.Second, "wait before the first retry, doubled for every further retry")
+	cmd.Flags().DurationVar(&core.RetryMaxBackoff, "retry-max-backoff", 30*time.Second, "longest wait between two retries")
+	cmd.Flags().Int64Var(&core.MultipartPartSize, "part-size", 16, "multipart upload part size in MB")
+	cmd.Flags().IntVar(&core.MultipartConcurrency, "part-concurrency", 4, "number of parts of one object uploaded in parallel")
+	cmd.Flags().Int64Var(&core.MultipartThreshold, "multipart-threshold", 64, "objects from this size in MB on are uploaded in parts, at least --part-size")
+	cmd.Flags().BoolVar(&core.PreserveMetadata, "preserve-metadata", true, "copy content type, cache headers and user metadata of the source objects")
+	cmd.Flags().BoolVar(&core.PreserveACL, "preserve-acl", false, "copy the ACLs of the source buckets and objects")
+	cmd.Flags().StringVar(&core.ACLUserMap, "acl-user-map", "", "properties file mapping source user IDs to target user IDs, as source-id = target-id")
+	cmd.Flags().BoolVar(&core.PreserveTags, "preserve-tags", false, "copy the tags of the source objects")
+	cmd.Flags().BoolVar(&core.PreserveStorageClass, "preserve-storage-class", false, "write objects to the storage class of their source object")
+	cmd.Flags().StringVar(&core.StorageClassMap, "storage-class-map", "", "properties file mapping source storage classes to target ones, like IA = COLD")
+	cmd.Flags().StringVar(&core.StorageClass, "storage-class", "", "write every object to this storage class, like a placement target storage class of Ceph")
+	cmd.Flags().BoolVar(&core.ServerSideCopy, "server-side-copy", true, "copy objects within the target store when the source is the same store with the same credentials")
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"github.com/shangjin92/ceph-sync/core"
+	"github.com/spf13/cobra"
+)
+
+var retryFailedCmd = &cobra.Command{
+	Use:   "retry",
+	Short: "retry the failed objects of a failure manifest",
+	Long: `ceph-sync retry --config /root/sync.properties --source-type ceph \
+      --manifest failed.jsonl`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// the objects that still fail replace the manifest unless another file is asked for
+		if !cmd.Flags().Changed("failure-manifest") {
+			core.FailureManifest = core.RetryManifest
+		}
+		exitOnError(core.RetryFailedObjects())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(retryFailedCmd)
+
+	retryFailedCmd.Flags().StringVar(&core.SyncProperties, "config", "/root/sync.properties", "ceph bucket sync config")
+	retryFailedCmd.Flags().StringVar(&core.SourceType, "source-type", "", "source type, maybe: oss/ceph/s3/local")
+	retryFailedCmd.Flags().StringVar(&core.SourceLocalDirName, "source-dir-path", "", "local directory the failed objects were uploaded from, for local source")
+	retryFailedCmd.Flags().StringVar(&core.TargetType, "target-type", "ceph", "target type, maybe: ceph/s3/oss/local")
+	retryFailedCmd.Flags().StringVar(&core.TargetLocalDirName, "target-dir-path", "", "local directory buckets are exported to, for local target")
+	retryFailedCmd.Flags().StringVar(&core.RetryManifest, "manifest", "failed.jsonl", "failure manifest written by a previous run, it is replaced by the objects that still fail unless --failure-manifest names another file")
+	retryFailedCmd.Flags().StringVar(&core.VersionMap, "version-map", "version-map.jsonl", "version map of the --all-versions run, the replayed versions of the failed objects are appended to it")
+
+	addTransferFlags(retryFailedCmd)
+}
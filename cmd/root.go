@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"github.com/shangjin92/ceph-sync/core"
 	"github.com/shangjin92/ceph-sync/internal/utils/logger"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -24,7 +25,14 @@ var rootCmd = &cobra.Command{
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(core.ExitConfigError)
+	}
+}
+
+// exitOnError ends the process with the exit code for err, if there is one.
+func exitOnError(err error) {
+	if err != nil {
+		os.Exit(core.ExitCode(err))
 	}
 }
 
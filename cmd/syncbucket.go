@@ -12,7 +12,7 @@ var syncBucketCmd = &cobra.Command{
       --target-bucket bucket-name \
       --target-object-prefix file-prefix`,
 	Run: func(cmd *cobra.Command, args []string) {
-		core.SyncClusterBucketData()
+		exitOnError(core.SyncClusterBucketData())
 	},
 }
 
@@ -20,10 +20,24 @@ func init() {
 	rootCmd.AddCommand(syncBucketCmd)
 
 	syncBucketCmd.Flags().StringVar(&core.SyncProperties, "config", "/root/sync.properties", "ceph bucket sync config")
-	syncBucketCmd.Flags().StringVar(&core.SourceType, "source-type", "", "source type, maybe: oss/ceph/local")
+	syncBucketCmd.Flags().StringVar(&core.SourceType, "source-type", "", "source type, maybe: oss/ceph/s3/local")
 	syncBucketCmd.Flags().StringVar(&core.SourceLocalDirName, "source-dir-path", "", "local directory to be uploaded")
+	syncBucketCmd.Flags().BoolVar(&core.IncludeRootDir, "include-root-dir", false, "prefix object keys with the name of source-dir-path, for local source")
 	syncBucketCmd.Flags().StringVar(&core.SourceClusterBucket, "source-bucket", "", "bucket name of source cluster")
 	syncBucketCmd.Flags().StringVar(&core.SourceClusterObjectPrefix, "source-object-prefix", "", "object's prefix in source bucket")
+	syncBucketCmd.Flags().StringVar(&core.TargetType, "target-type", "ceph", "target type, maybe: ceph/s3/oss/local")
+	syncBucketCmd.Flags().StringVar(&core.TargetLocalDirName, "target-dir-path", "", "local directory buckets are exported to, for local target")
 	syncBucketCmd.Flags().StringVar(&core.TargetClusterBucket, "target-bucket", "", "bucket name of target cluster")
 	syncBucketCmd.Flags().StringVar(&core.TargetClusterObjectPrefix, "target-object-prefix", "", "object's prefix in target bucket")
+	syncBucketCmd.Flags().BoolVar(&core.StripSourcePrefix, "strip-source-prefix", false, "remove source-object-prefix from the keys before target-object-prefix is prepended")
+	syncBucketCmd.Flags().BoolVar(&core.MirrorDelete, "delete", false, "delete target objects under target-object-prefix that no longer exist at the source")
+	syncBucketCmd.Flags().IntVar(&core.MaxDelete, "max-delete", -1, "delete nothing when more objects than this would be deleted, -1 for no limit")
+	syncBucketCmd.Flags().BoolVar(&core.DeleteDryRun, "delete-dry-run", false, "only print the objects --delete would remove")
+
+	addFilterFlags(syncBucketCmd)
+	addRewriteFlags(syncBucketCmd)
+	addBucketFlags(syncBucketCmd)
+	addVersionFlags(syncBucketCmd)
+	addCheckpointFlags(syncBucketCmd)
+	addTransferFlags(syncBucketCmd)
 }
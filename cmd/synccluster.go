@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"fmt"
 	"github.com/shangjin92/ceph-sync/core"
 	"github.com/spf13/cobra"
 )
@@ -9,9 +8,9 @@ import (
 var syncCmd = &cobra.Command{
 	Use:   "cluster",
 	Short: "sync ceph cluster data",
-	Long:  `ceph-sync cluster --config /root/sync.properties`,
+	Long:  `ceph-sync cluster --config /root/sync.properties --source-type ceph`,
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("To be realized")
+		exitOnError(core.SyncClusterData())
 	},
 }
 
@@ -19,4 +18,14 @@ func init() {
 	rootCmd.AddCommand(syncCmd)
 
 	syncCmd.Flags().StringVar(&core.SyncProperties, "config", "/root/sync.properties", "ceph cluster sync config")
+	syncCmd.Flags().StringVar(&core.SourceType, "source-type", "", "source type, maybe: oss/ceph/s3")
+	syncCmd.Flags().StringVar(&core.TargetType, "target-type", "ceph", "target type, maybe: ceph/s3/oss/local")
+	syncCmd.Flags().StringVar(&core.TargetLocalDirName, "target-dir-path", "", "local directory buckets are exported to, for local target")
+
+	addFilterFlags(syncCmd)
+	addRewriteFlags(syncCmd)
+	addBucketFlags(syncCmd)
+	addVersionFlags(syncCmd)
+	addCheckpointFlags(syncCmd)
+	addTransferFlags(syncCmd)
 }
@@ -0,0 +1,168 @@
+package core
+
+import (
+	"errors"
+	"github.com/magiconair/properties"
+	"github.com/shangjin92/ceph-sync/internal/store"
+	"github.com/sirupsen/logrus"
+	"reflect"
+	"strings"
+)
+
+// userMapper maps the user IDs of the source cluster to the ones of the target
+// cluster, IDs that are not mapped are kept.
+type userMapper struct {
+	users map[string]string
+}
+
+// newUserMapper loads ACLUserMap, a properties file of "source-id = target-id"
+// lines. It returns nil when no file is given.
+func newUserMapper() (*userMapper, error) {
+	if ACLUserMap == "" {
+		return nil, nil
+	}
+	p, err := properties.LoadFile(ACLUserMap, properties.UTF8)
+	if err != nil {
+		return nil, err
+	}
+	mapper := &userMapper{users: make(map[string]string)}
+	for _, key := range p.Keys() {
+		mapper.users[key] = p.MustGetString(key)
+	}
+	return mapper, nil
+}
+
+func (mapper *userMapper) user(id string) string {
+	if mapper == nil || id == "" {
+		return id
+	}
+	if mapped, ok := mapper.users[id]; ok {
+		return mapped
+	}
+	return id
+}
+
+// mapACL returns a copy of acl with the owner and user grantees mapped.
+func (mapper *userMapper) mapACL(acl *store.ACL) *store.ACL {
+	mapped := &store.ACL{
+		Canned: acl.Canned,
+		Owner:  mapper.user(acl.Owner),
+	}
+	for _, grant := range acl.Grants {
+		if grant.GranteeType == store.GranteeCanonicalUser {
+			grant.ID = mapper.user(grant.ID)
+		}
+		mapped.Grants = append(mapped.Grants, grant)
+	}
+	return mapped
+}
+
+// mapPolicy rewrites the resources of a bucket policy to the target bucket, and
+// its user principals to the users of the target cluster.
+func (mapper *userMapper) mapPolicy(policy, sourceBucket, targetBucket string) string {
+	var replacements []string
+	if sourceBucket != targetBucket {
+		for _, resource := range []string{"arn:aws:s3:::", "acs:oss:*:*:"} {
+			for _, end := range []string{`"`, "/"} {
+				replacements = append(replacements, resource+sourceBucket+end, resource+targetBucket+end)
+			}
+		}
+	}
+	if mapper != nil {
+		for source, target := range mapper.users {
+			replacements = append(replacements, ":user/"+source+`"`, ":user/"+target+`"`)
+		}
+	}
+	return strings.NewReplacer(replacements...).Replace(policy)
+}
+
+// checkAccessSupport returns an error when the ACLs, tags, bucket policies or
+// CORS rules asked to be copied are not supported by the source or the target.
+func checkAccessSupport(sourceClient, targetClient store.Store) error {
+	if _, ok := sourceClient.(store.TaggingStore); PreserveTags && !ok {
+		return errors.New("--preserve-tags needs a source with object tags, like ceph, s3 or oss")
+	}
+	if PreserveACL {
+		_, sourceOk := sourceClient.(store.ACLStore)
+		_, targetOk := targetClient.(store.ACLStore)
+		if !sourceOk || !targetOk {
+			return errors.New("--preserve-acl needs a source and a target with ACLs, like ceph, s3 or oss")
+		}
+	}
+	if CopyBucketPolicy || CopyBucketCORS {
+		_, sourceOk := sourceClient.(store.BucketPolicyStore)
+		_, targetOk := targetClient.(store.BucketPolicyStore)
+		if !sourceOk || !targetOk {
+			return errors.New("--copy-bucket-policy and --copy-bucket-cors need a source and a target bucket, like ceph, s3 or oss")
+		}
+	}
+	return nil
+}
+
+// syncBucketAccess copies the ACL, policy and CORS rules of the source bucket to
+// the target bucket, as far as they are asked for.
+func syncBucketAccess(sourceClient, targetClient store.Store, job *bucketSyncJob) error {
+	if PreserveACL {
+		acl, err := sourceClient.(store.ACLStore).GetBucketACL(job.sourceBucket)
+		if err != nil {
+			logrus.Errorf("get source bucket acl failed, bucket: %s, error: %v", job.sourceBucket, err)
+			return sourceError(err)
+		}
+		if err = targetClient.(store.ACLStore).PutBucketACL(job.targetBucket, job.users.mapACL(acl)); err != nil {
+			logrus.Errorf("put target bucket acl failed, bucket: %s, error: %v", job.targetBucket, err)
+			return targetError(err)
+		}
+	}
+
+	if CopyBucketPolicy {
+		policy, err := sourceClient.(store.BucketPolicyStore).GetBucketPolicy(job.sourceBucket)
+		if err != nil {
+			logrus.Errorf("get source bucket policy failed, bucket: %s, error: %v", job.sourceBucket, err)
+			return sourceError(err)
+		}
+		if policy != "" && reflect.TypeOf(sourceClient) != reflect.TypeOf(targetClient) {
+			// the policy languages of s3 and oss differ
+			logrus.Warnf("bucket policy can't be copied between %s and %s, bucket: %s", SourceType, TargetType, job.sourceBucket)
+		} else if policy != "" {
+			policy = job.users.mapPolicy(policy, job.sourceBucket, job.targetBucket)
+			if err = targetClient.(store.BucketPolicyStore).PutBucketPolicy(job.targetBucket, policy); err != nil {
+				logrus.Errorf("put target bucket policy failed, bucket: %s, error: %v", job.targetBucket, err)
+				return targetError(err)
+			}
+		}
+	}
+
+	if CopyBucketCORS {
+		rules, err := sourceClient.(store.BucketPolicyStore).GetBucketCORS(job.sourceBucket)
+		if err != nil {
+			logrus.Errorf("get source bucket cors failed, bucket: %s, error: %v", job.sourceBucket, err)
+			return sourceError(err)
+		}
+		if len(rules) > 0 {
+			if err = targetClient.(store.BucketPolicyStore).PutBucketCORS(job.targetBucket, rules); err != nil {
+				logrus.Errorf("put target bucket cors failed, bucket: %s, error: %v", job.targetBucket, err)
+				return targetError(err)
+			}
+		}
+	}
+	return nil
+}
+
+// copyObjectACL copies the ACL of a source object to its target object, objects
+// inheriting the ACL of their bucket are left alone.
+func copyObjectACL(sourceClient, targetClient store.Store, job *bucketSyncJob, task *syncTask) error {
+	acl, err := sourceClient.(store.ACLStore).GetObjectACL(job.sourceBucket, task.key)
+	if err != nil {
+		logrus.Errorf("get source object acl failed, object name: %s, error: %v", task.key, err)
+		return err
+	}
+	if acl == nil {
+		return nil
+	}
+	err = targetClient.(store.ACLStore).PutObjectACL(job.targetBucket, task.targetKey, job.users.mapACL(acl))
+	if err != nil {
+		logrus.Errorf("put target object acl failed, bucket: %s, name: %s, error: %v", job.targetBucket, task.targetKey, err)
+		return err
+	}
+	return nil
+}
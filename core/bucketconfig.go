@@ -0,0 +1,320 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/shangjin92/ceph-sync/internal/store"
+	"github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+)
+
+const (
+	ConfigVersioning = "versioning"
+	ConfigLifecycle  = "lifecycle"
+	ConfigCORS       = "cors"
+	ConfigTags       = "tags"
+	ConfigWebsite    = "website"
+	ConfigEncryption = "encryption"
+)
+
+// BucketConfigNames are the bucket configurations bucket-config syncs by default.
+var BucketConfigNames = []string{ConfigVersioning, ConfigLifecycle, ConfigCORS, ConfigTags, ConfigWebsite, ConfigEncryption}
+
+// unsupportedBucketConfigs are the bucket configurations bucket-config can't sync,
+// with the reason why.
+var unsupportedBucketConfigs = map[string]string{
+	"quota": "bucket quotas are not part of the S3 API, set them on the target cluster with radosgw-admin quota set",
+}
+
+// bucketConfigItem reads and writes one kind of bucket configuration. Source and
+// target configurations are compared as json, so read returns them in a canonical
+// form, and none is what a bucket without the configuration reads as.
+type bucketConfigItem struct {
+	name  string
+	none  interface{}
+	read  func(client store.BucketConfigStore, bucketName string) (interface{}, error)
+	write func(client store.BucketConfigStore, bucketName string, config interface{}) error
+}
+
+var bucketConfigItems = map[string]*bucketConfigItem{
+	ConfigVersioning: {
+		name: ConfigVersioning,
+		none: "",
+		// a suspended bucket reads like one that was never versioned, both
+		// keep no new versions
+		read: func(client store.BucketConfigStore, bucketName string) (interface{}, error) {
+			status, err := client.GetBucketVersioning(bucketName)
+			if status == store.VersioningSuspended {
+				status = ""
+			}
+			return status, err
+		},
+		write: func(client store.BucketConfigStore, bucketName string, config interface{}) error {
+			status := config.(string)
+			if status == "" {
+				status = store.VersioningSuspended
+			}
+			return client.PutBucketVersioning(bucketName, status)
+		},
+	},
+	ConfigLifecycle: {
+		name: ConfigLifecycle,
+		read: func(client store.BucketConfigStore, bucketName string) (interface{}, error) {
+			rules, err := client.GetBucketLifecycle(bucketName)
+			if len(rules) == 0 {
+				return []store.LifecycleRule(nil), err
+			}
+			sort.SliceStable(rules, func(i, j int) bool {
+				return rules[i].ID < rules[j].ID
+			})
+			return rules, err
+		},
+		write: func(client store.BucketConfigStore, bucketName string, config interface{}) error {
+			return client.PutBucketLifecycle(bucketName, config.([]store.LifecycleRule))
+		},
+	},
+	ConfigCORS: {
+		name: ConfigCORS,
+		read: func(client store.BucketConfigStore, bucketName string) (interface{}, error) {
+			rules, err := client.(store.BucketPolicyStore).GetBucketCORS(bucketName)
+			if len(rules) == 0 {
+				return []store.CORSRule(nil), err
+			}
+			return rules, err
+		},
+		write: func(client store.BucketConfigStore, bucketName string, config interface{}) error {
+			return client.(store.BucketPolicyStore).PutBucketCORS(bucketName, config.([]store.CORSRule))
+		},
+	},
+	ConfigTags: {
+		name: ConfigTags,
+		read: func(client store.BucketConfigStore, bucketName string) (interface{}, error) {
+			tags, err := client.GetBucketTagging(bucketName)
+			if len(tags) == 0 {
+				return map[string]string(nil), err
+			}
+			return tags, err
+		},
+		write: func(client store.BucketConfigStore, bucketName string, config interface{}) error {
+			return client.PutBucketTagging(bucketName, config.(map[string]string))
+		},
+	},
+	ConfigWebsite: {
+		name: ConfigWebsite,
+		read: func(client store.BucketConfigStore, bucketName string) (interface{}, error) {
+			return client.GetBucketWebsite(bucketName)
+		},
+		write: func(client store.BucketConfigStore, bucketName string, config interface{}) error {
+			return client.PutBucketWebsite(bucketName, config.(*store.WebsiteConfig))
+		},
+	},
+	ConfigEncryption: {
+		name: ConfigEncryption,
+		read: func(client store.BucketConfigStore, bucketName string) (interface{}, error) {
+			return client.GetBucketEncryption(bucketName)
+		},
+		write: func(client store.BucketConfigStore, bucketName string, config interface{}) error {
+			return client.PutBucketEncryption(bucketName, config.(*store.BucketEncryption))
+		},
+	},
+}
+
+// selectBucketConfigItems returns the items of BucketConfigs, in the order of
+// BucketConfigNames.
+func selectBucketConfigItems() ([]*bucketConfigItem, error) {
+	selected := make(map[string]bool)
+	for _, name := range BucketConfigs {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if reason, ok := unsupportedBucketConfigs[name]; ok {
+			return nil, fmt.Errorf("bucket config %s is not supported, %s", name, reason)
+		}
+		if _, ok := bucketConfigItems[name]; !ok {
+			return nil, fmt.Errorf("unknown bucket config: %q, maybe: %s", name, strings.Join(BucketConfigNames, "/"))
+		}
+		selected[name] = true
+	}
+
+	var items []*bucketConfigItem
+	for _, name := range BucketConfigNames {
+		if selected[name] {
+			items = append(items, bucketConfigItems[name])
+		}
+	}
+	return items, nil
+}
+
+// bucketConfigSummary counts what happened to the configurations of the buckets.
+type bucketConfigSummary struct {
+	buckets int
+	changed int
+	failed  int
+}
+
+// SyncBucketConfig copies the configuration of source buckets to the target
+// buckets, a configuration that fails doesn't stop the others.
+func SyncBucketConfig() error {
+	logrus.Info("Begin sync bucket config...")
+
+	summary := &bucketConfigSummary{}
+	err := syncBucketConfig(summary)
+	logrus.Infof("bucket config summary, buckets: %d, changed: %d, failed: %d", summary.buckets, summary.changed, summary.failed)
+	if err == nil && summary.failed > 0 {
+		err = fmt.Errorf("%d bucket configs failed", summary.failed)
+	}
+
+	logrus.Info("Finished sync bucket config...")
+	return err
+}
+
+func syncBucketConfig(summary *bucketConfigSummary) error {
+	items, err := selectBucketConfigItems()
+	if err != nil {
+		logrus.Error(err)
+		return configError(err)
+	}
+	classes, err := loadStorageClassMap()
+	if err != nil {
+		logrus.Errorf("load storage class map failed, error: %v", err)
+		return configError(err)
+	}
+
+	sourceStoreClient, targetStoreClient, err := newStoreClients()
+	if err != nil {
+		return err
+	}
+	sourceClient, sourceOk := sourceStoreClient.(store.BucketConfigStore)
+	targetClient, targetOk := targetStoreClient.(store.BucketConfigStore)
+	if !sourceOk || !targetOk {
+		err = errors.New("bucket-config needs a source and a target bucket, like ceph, s3 or oss")
+		logrus.Error(err)
+		return configError(err)
+	}
+	for _, item := range items {
+		_, sourceOk = sourceStoreClient.(store.BucketPolicyStore)
+		_, targetOk = targetStoreClient.(store.BucketPolicyStore)
+		if item.name == ConfigCORS && (!sourceOk || !targetOk) {
+			err = errors.New("bucket-config of cors needs a source and a target with CORS rules, like ceph, s3 or oss")
+			logrus.Error(err)
+			return configError(err)
+		}
+	}
+
+	var bucketNames []string
+	if SourceClusterBucket != "" {
+		bucketNames = []string{SourceClusterBucket}
+	} else {
+		var listBucketsResult *store.ListBucketsResult
+		_, err = withRetry("list source buckets", func() (err error) {
+			listBucketsResult, err = sourceStoreClient.ListBuckets()
+			return err
+		})
+		if err != nil {
+			logrus.Errorf("list source buckets failed, error: %v", err)
+			return sourceError(err)
+		}
+		bucketNames = listBucketsResult.BucketNames
+	}
+
+	for _, sourceBucket := range bucketNames {
+		targetBucket := sourceBucket
+		if SourceClusterBucket != "" && TargetClusterBucket != "" {
+			targetBucket = TargetClusterBucket
+		}
+		summary.buckets++
+
+		targetMissing := false
+		if DryRun {
+			exist, _ := targetStoreClient.CheckBucketExist(targetBucket)
+			targetMissing = !exist
+		} else if _, err = createBucketIfAbsent(targetBucket, targetStoreClient); err != nil {
+			logrus.Errorf("create bucket failed, bucket name: %s", targetBucket)
+			summary.failed += len(items)
+			continue
+		}
+
+		for _, item := range items {
+			changed, err := syncBucketConfigItem(sourceClient, targetClient, item, sourceBucket, targetBucket, targetMissing, classes)
+			if err != nil {
+				summary.failed++
+			} else if changed {
+				summary.changed++
+			}
+		}
+	}
+	return nil
+}
+
+// readBucketConfig reads a configuration of a bucket, retrying transient failures.
+func readBucketConfig(client store.BucketConfigStore, item *bucketConfigItem, bucketName string) (interface{}, error) {
+	var config interface{}
+	_, err := withRetry("get bucket "+item.name+" of bucket "+bucketName, func() (err error) {
+		config, err = item.read(client, bucketName)
+		return err
+	})
+	return config, err
+}
+
+// syncBucketConfigItem writes a configuration of the source bucket to the target
+// bucket when they differ, and reports whether they did.
+func syncBucketConfigItem(sourceClient, targetClient store.BucketConfigStore, item *bucketConfigItem,
+	sourceBucket, targetBucket string, targetMissing bool, classes map[string]string) (bool, error) {
+	sourceConfig, err := readBucketConfig(sourceClient, item, sourceBucket)
+	if err != nil {
+		logrus.Errorf("get source bucket %s failed, bucket: %s, error: %v", item.name, sourceBucket, err)
+		return false, err
+	}
+	if rules, ok := sourceConfig.([]store.LifecycleRule); ok {
+		mapTransitionStorageClasses(rules, classes)
+	}
+
+	targetConfig := item.none
+	if !targetMissing {
+		if targetConfig, err = readBucketConfig(targetClient, item, targetBucket); err != nil {
+			logrus.Errorf("get target bucket %s failed, bucket: %s, error: %v", item.name, targetBucket, err)
+			return false, err
+		}
+	}
+
+	sourceJSON, err := json.Marshal(sourceConfig)
+	if err != nil {
+		return false, err
+	}
+	targetJSON, err := json.Marshal(targetConfig)
+	if err != nil {
+		return false, err
+	}
+	if string(sourceJSON) == string(targetJSON) {
+		logrus.Infof("bucket %s is in sync, bucket: %s", item.name, targetBucket)
+		return false, nil
+	}
+
+	logrus.Infof("bucket %s differs, bucket: %s, source: %s, target: %s", item.name, targetBucket, sourceJSON, targetJSON)
+	if DryRun {
+		return true, nil
+	}
+	_, err = withRetry("put bucket "+item.name+" of bucket "+targetBucket, func() error {
+		return item.write(targetClient, targetBucket, sourceConfig)
+	})
+	if err != nil {
+		logrus.Errorf("put target bucket %s failed, bucket: %s, error: %v", item.name, targetBucket, err)
+		return false, err
+	}
+	logrus.Infof("put target bucket %s successful, bucket: %s", item.name, targetBucket)
+	return true, nil
+}
+
+// mapTransitionStorageClasses maps the storage classes of lifecycle transitions
+// through the storage class map, classes missing from it are kept.
+func mapTransitionStorageClasses(rules []store.LifecycleRule, classes map[string]string) {
+	mapper := &storageClassMapper{classes: classes}
+	for i := range rules {
+		for j := range rules[i].Transitions {
+			rules[i].Transitions[j].StorageClass = mapper.storageClass(rules[i].Transitions[j].StorageClass)
+		}
+		for j := range rules[i].NoncurrentTransitions {
+			rules[i].NoncurrentTransitions[j].StorageClass = mapper.storageClass(rules[i].NoncurrentTransitions[j].StorageClass)
+		}
+	}
+}
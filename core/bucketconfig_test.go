@@ -0,0 +1,87 @@
+package core
+
+import (
+	"github.com/shangjin92/ceph-sync/internal/store"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSelectBucketConfigItems(t *testing.T) {
+	tests := []struct {
+		configs []string
+		names   []string
+		err     string
+	}{
+		{configs: BucketConfigNames, names: BucketConfigNames},
+		{configs: []string{"encryption", " CORS ", "versioning"}, names: []string{"versioning", "cors", "encryption"}},
+		{configs: []string{"quota"}, err: "radosgw-admin quota set"},
+		{configs: []string{"lifecycle", "acl"}, err: "unknown bucket config"},
+	}
+
+	for _, test := range tests {
+		BucketConfigs = test.configs
+		items, err := selectBucketConfigItems()
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("select %v error = %v, want an error containing %q", test.configs, err, test.err)
+			}
+			continue
+		}
+		var names []string
+		for _, item := range items {
+			names = append(names, item.name)
+		}
+		if err != nil || !reflect.DeepEqual(names, test.names) {
+			t.Errorf("select %v = %v, error: %v, want %v", test.configs, names, err, test.names)
+		}
+	}
+	BucketConfigs = nil
+}
+
+func TestSyncBucketConfigItemRetry(t *testing.T) {
+	RetryBackoff, RetryMaxBackoff, RetryAttempts = time.Microsecond, time.Microsecond, 2
+	defer func() {
+		RetryBackoff, RetryMaxBackoff, RetryAttempts = 0, 0, 0
+	}()
+
+	// every call fails once with a transient error before it succeeds
+	calls := make(map[string]int)
+	transient := func(call string) error {
+		calls[call]++
+		if calls[call] == 1 {
+			return &store.HttpStatusError{StatusCode: 503, Status: "503 Service Unavailable"}
+		}
+		return nil
+	}
+	var written interface{}
+	item := &bucketConfigItem{
+		name: ConfigTags,
+		read: func(client store.BucketConfigStore, bucketName string) (interface{}, error) {
+			if err := transient("get " + bucketName); err != nil {
+				return nil, err
+			}
+			return map[string]string{"bucket": bucketName}, nil
+		},
+		write: func(client store.BucketConfigStore, bucketName string, config interface{}) error {
+			if err := transient("put " + bucketName); err != nil {
+				return err
+			}
+			written = config
+			return nil
+		},
+	}
+
+	changed, err := syncBucketConfigItem(nil, nil, item, "source", "target", false, nil)
+	if err != nil || !changed {
+		t.Fatalf("sync bucket config changed: %t, error: %v, want it changed", changed, err)
+	}
+	want := map[string]int{"get source": 2, "get target": 2, "put target": 2}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+	if !reflect.DeepEqual(written, map[string]string{"bucket": "source"}) {
+		t.Errorf("written config = %v, want the source config", written)
+	}
+}
@@ -0,0 +1,266 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// checkpoint persists the progress of bucket syncs in a json file, so an
+// interrupted run can be resumed after the last fully completed listing page.
+type checkpoint struct {
+	lock     sync.Mutex
+	fileName string
+	Buckets  map[string]*bucketCheckpoint `json:"buckets"`
+}
+
+// bucketCheckpoint is the progress of one bucketSyncJob. Every object up to and
+// including Marker has been handled, Failed lists the objects among them that
+// could not be copied.
+type bucketCheckpoint struct {
+	SourceBucket string            `json:"source_bucket"`
+	TargetBucket string            `json:"target_bucket"`
+	Options      checkpointOptions `json:"options"`
+	Marker       string            `json:"marker"`
+	Completed    int64             `json:"completed"`
+	Failed       []*failedObject   `json:"failed,omitempty"`
+	Finished     bool              `json:"finished"`
+}
+
+// checkpointOptions are the options deciding which objects a job syncs and
+// which keys they get on the target. A job only resumes from a checkpoint saved
+// with the same options, its marker means nothing for other ones.
+type checkpointOptions struct {
+	AllVersions       bool     `json:"all_versions,omitempty"`
+	FilterRules       []string `json:"filter_rules,omitempty"`
+	MinSize           string   `json:"min_size,omitempty"`
+	MaxSize           string   `json:"max_size,omitempty"`
+	ModifiedAfter     string   `json:"modified_after,omitempty"`
+	ModifiedBefore    string   `json:"modified_before,omitempty"`
+	StripSourcePrefix bool     `json:"strip_source_prefix,omitempty"`
+	RewriteRules      []string `json:"rewrite_rules,omitempty"`
+	KeyTemplate       string   `json:"key_template,omitempty"`
+}
+
+func newCheckpointOptions(job *bucketSyncJob) checkpointOptions {
+	return checkpointOptions{
+		AllVersions:       job.allVersions,
+		FilterRules:       FilterRules,
+		MinSize:           MinSize,
+		MaxSize:           MaxSize,
+		ModifiedAfter:     ModifiedAfter,
+		ModifiedBefore:    ModifiedBefore,
+		StripSourcePrefix: job.stripSourcePrefix,
+		RewriteRules:      RewriteRules,
+		KeyTemplate:       KeyTemplate,
+	}
+}
+
+// equal compares options as they are saved, so a nil and an empty list of rules
+// are the same.
+func (options checkpointOptions) equal(other checkpointOptions) bool {
+	data, _ := json.Marshal(options)
+	otherData, _ := json.Marshal(other)
+	return string(data) == string(otherData)
+}
+
+type failedObject struct {
+	Key   string `json:"key"`
+	Error string `json:"error"`
+}
+
+// newRunCheckpoint returns the checkpoint of this run, nil when checkpoints are
+// disabled or nothing is changed because of a dry run.
+func newRunCheckpoint() (*checkpoint, error) {
+	if Resume && CheckpointFile == "" {
+		return nil, errors.New("--resume needs the --checkpoint file of the interrupted sync")
+	}
+	if CheckpointFile == "" || DryRun {
+		return nil, nil
+	}
+	return loadCheckpoint(CheckpointFile, Resume)
+}
+
+// loadCheckpoint reads the checkpoint file when resuming, a new run starts
+// with an empty checkpoint that replaces the file on the first save.
+func loadCheckpoint(fileName string, resume bool) (*checkpoint, error) {
+	cp := &checkpoint{
+		fileName: fileName,
+		Buckets:  make(map[string]*bucketCheckpoint),
+	}
+	if !resume {
+		return cp, nil
+	}
+
+	data, err := ioutil.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		logrus.Warnf("checkpoint file: %s not found, start from the beginning", fileName)
+		return cp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("parse checkpoint file: %s failed, error: %v", fileName, err)
+	}
+	if cp.Buckets == nil {
+		cp.Buckets = make(map[string]*bucketCheckpoint)
+	}
+	return cp, nil
+}
+
+func checkpointKey(job *bucketSyncJob) string {
+	return fmt.Sprintf("%s/%s -> %s/%s", job.sourceBucket, job.sourceObjectPrefix, job.targetBucket, job.targetObjectPrefix)
+}
+
+// bucket returns the progress of job, creating it when job has none yet. It
+// fails when the progress was saved with other options than the ones of job.
+func (cp *checkpoint) bucket(job *bucketSyncJob) (*bucketCheckpoint, error) {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+
+	key := checkpointKey(job)
+	options := newCheckpointOptions(job)
+	entry, ok := cp.Buckets[key]
+	if !ok {
+		entry = &bucketCheckpoint{
+			SourceBucket: job.sourceBucket,
+			TargetBucket: job.targetBucket,
+			Options:      options,
+		}
+		cp.Buckets[key] = entry
+	}
+	if !entry.Options.equal(options) {
+		return nil, fmt.Errorf("checkpoint of bucket: %s was saved with other filter, key mapping or --all-versions options, "+
+			"resume with the same options or start over without --resume", job.sourceBucket)
+	}
+	return entry, nil
+}
+
+// takeFailed returns the failed objects of entry and forgets them, they are
+// recorded again when they fail once more.
+func (cp *checkpoint) takeFailed(entry *bucketCheckpoint) []*failedObject {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+
+	failed := entry.Failed
+	entry.Failed = nil
+	return failed
+}
+
+func (cp *checkpoint) addFailed(entry *bucketCheckpoint, key string, err error) {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+
+	entry.Failed = append(entry.Failed, &failedObject{Key: key, Error: err.Error()})
+}
+
+// commit records that every object up to marker has been handled.
+func (cp *checkpoint) commit(entry *bucketCheckpoint, marker string, completed int64) {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+
+	entry.Marker = marker
+	entry.Completed += completed
+	cp.save()
+}
+
+func (cp *checkpoint) finish(entry *bucketCheckpoint) {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+
+	entry.Finished = true
+	cp.save()
+}
+
+// save writes the checkpoint to a temporary file and renames it over the
+// checkpoint file, so a crash never leaves a truncated checkpoint behind.
+// The caller must hold the lock.
+func (cp *checkpoint) save() {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		logrus.Errorf("marshal checkpoint failed, error: %v", err)
+		return
+	}
+
+	tmpFileName := cp.fileName + ".tmp"
+	if err = ioutil.WriteFile(tmpFileName, data, 0644); err != nil {
+		logrus.Errorf("write checkpoint file: %s failed, error: %v", tmpFileName, err)
+		return
+	}
+	if err = os.Rename(tmpFileName, cp.fileName); err != nil {
+		logrus.Errorf("rename checkpoint file: %s failed, error: %v", tmpFileName, err)
+	}
+}
+
+// listPage is one page of the source listing. It is done when every object of
+// it has been handled, and committed to the checkpoint once all pages before it
+// are done too.
+type listPage struct {
+	lastKey   string
+	objects   int64
+	failed    int64
+	pending   int64
+	completed bool
+}
+
+// pageTracker commits listing pages to the checkpoint in listing order.
+type pageTracker struct {
+	lock       sync.Mutex
+	checkpoint *checkpoint
+	entry      *bucketCheckpoint
+	pages      []*listPage
+}
+
+func newPageTracker(cp *checkpoint, entry *bucketCheckpoint) *pageTracker {
+	return &pageTracker{
+		checkpoint: cp,
+		entry:      entry,
+	}
+}
+
+// newPage starts tracking a page of objects objects. The page holds an extra
+// pending count until it is sealed, so it cannot complete while its objects
+// are still being submitted.
+func (tracker *pageTracker) newPage(lastKey string, objects int) *listPage {
+	page := &listPage{
+		lastKey: lastKey,
+		objects: int64(objects),
+		pending: int64(objects) + 1,
+	}
+
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	tracker.pages = append(tracker.pages, page)
+	return page
+}
+
+// seal marks that every object of page has been submitted.
+func (tracker *pageTracker) seal(page *listPage) {
+	tracker.done(page, nil)
+}
+
+// done marks one object of page as handled, err is set when it failed.
+func (tracker *pageTracker) done(page *listPage, err error) {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+
+	if err != nil {
+		page.failed++
+	}
+	page.pending--
+	if page.pending > 0 {
+		return
+	}
+	page.completed = true
+
+	for len(tracker.pages) > 0 && tracker.pages[0].completed {
+		committed := tracker.pages[0]
+		tracker.pages = tracker.pages[1:]
+		tracker.checkpoint.commit(tracker.entry, committed.lastKey, committed.objects-committed.failed)
+	}
+}
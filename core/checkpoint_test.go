@@ -0,0 +1,237 @@
+package core
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// trackerStep is one call to a pageTracker: "new" starts a page of objects
+// objects ending at key, "seal" seals page, "done" handles an object of page and
+// "fail" fails the object key of page. marker is the checkpoint marker after it.
+type trackerStep struct {
+	op      string
+	page    int
+	key     string
+	objects int
+	marker  string
+}
+
+func TestPageTracker(t *testing.T) {
+	tests := []struct {
+		name      string
+		steps     []trackerStep
+		completed int64
+		failed    []string
+	}{
+		{
+			name: "pages in order",
+			steps: []trackerStep{
+				{op: "new", key: "b", objects: 2},
+				{op: "seal", page: 0},
+				{op: "new", key: "c", objects: 1},
+				{op: "seal", page: 1},
+				{op: "done", page: 0},
+				{op: "done", page: 0, marker: "b"},
+				{op: "done", page: 1, marker: "c"},
+			},
+			completed: 3,
+		},
+		{
+			name: "pages finishing out of order",
+			steps: []trackerStep{
+				{op: "new", key: "a", objects: 1},
+				{op: "new", key: "b", objects: 1},
+				{op: "new", key: "c", objects: 1},
+				{op: "seal", page: 0},
+				{op: "seal", page: 1},
+				{op: "seal", page: 2},
+				{op: "done", page: 2},
+				{op: "done", page: 1},
+				{op: "done", page: 0, marker: "c"},
+			},
+			completed: 3,
+		},
+		{
+			name: "page not sealed yet",
+			steps: []trackerStep{
+				{op: "new", key: "a", objects: 1},
+				{op: "done", page: 0},
+				{op: "new", key: "b", objects: 1},
+				{op: "seal", page: 1},
+				{op: "done", page: 1},
+				{op: "seal", page: 0, marker: "b"},
+			},
+			completed: 2,
+		},
+		{
+			name: "empty page",
+			steps: []trackerStep{
+				{op: "new", key: "a", objects: 0},
+				{op: "seal", page: 0, marker: "a"},
+			},
+		},
+		{
+			name: "failed object kept for resume",
+			steps: []trackerStep{
+				{op: "new", key: "b", objects: 2},
+				{op: "seal", page: 0},
+				{op: "fail", page: 0, key: "a"},
+				{op: "done", page: 0, marker: "b"},
+			},
+			completed: 1,
+			failed:    []string{"a"},
+		},
+		{
+			name: "unfinished page holds back a failed page",
+			steps: []trackerStep{
+				{op: "new", key: "a", objects: 1},
+				{op: "new", key: "b", objects: 1},
+				{op: "seal", page: 0},
+				{op: "seal", page: 1},
+				{op: "fail", page: 1, key: "b"},
+				{op: "done", page: 0, marker: "b"},
+			},
+			completed: 1,
+			failed:    []string{"b"},
+		},
+	}
+
+	for _, test := range tests {
+		cp, _ := loadCheckpoint(filepath.Join(t.TempDir(), "checkpoint.json"), false)
+		entry, _ := cp.bucket(&bucketSyncJob{sourceBucket: "source", targetBucket: "target"})
+		tracker := newPageTracker(cp, entry)
+
+		var pages []*listPage
+		for i, step := range test.steps {
+			switch step.op {
+			case "new":
+				pages = append(pages, tracker.newPage(step.key, step.objects))
+			case "seal":
+				tracker.seal(pages[step.page])
+			case "done":
+				tracker.done(pages[step.page], nil)
+			case "fail":
+				// like a pool worker, the failed object is recorded before its page is done
+				err := errors.New("copy failed")
+				cp.addFailed(entry, step.key, err)
+				tracker.done(pages[step.page], err)
+			}
+			if entry.Marker != step.marker {
+				t.Errorf("%s: marker after step %d (%s of page %d) = %q, want %q", test.name, i, step.op, step.page, entry.Marker, step.marker)
+			}
+		}
+
+		if entry.Completed != test.completed {
+			t.Errorf("%s: completed = %d, want %d", test.name, entry.Completed, test.completed)
+		}
+		var failed []string
+		for _, object := range entry.Failed {
+			failed = append(failed, object.Key)
+		}
+		if !reflect.DeepEqual(failed, test.failed) {
+			t.Errorf("%s: failed = %v, want %v", test.name, failed, test.failed)
+		}
+	}
+}
+
+func TestCheckpointResume(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "checkpoint.json")
+	job := &bucketSyncJob{sourceBucket: "source", targetBucket: "target"}
+
+	// the interrupted run handled the page a, b with a failed, and was
+	// interrupted while copying the page c, d
+	cp, err := loadCheckpoint(fileName, false)
+	if err != nil {
+		t.Fatalf("load new checkpoint failed, error: %v", err)
+	}
+	entry, _ := cp.bucket(job)
+	tracker := newPageTracker(cp, entry)
+	first := tracker.newPage("b", 2)
+	second := tracker.newPage("d", 2)
+	tracker.seal(first)
+	tracker.seal(second)
+	cp.addFailed(entry, "a", errors.New("copy failed"))
+	tracker.done(first, errors.New("copy failed"))
+	tracker.done(first, nil)
+	tracker.done(second, nil)
+
+	resumed, err := loadCheckpoint(fileName, true)
+	if err != nil {
+		t.Fatalf("load saved checkpoint failed, error: %v", err)
+	}
+	if restarted, _ := loadCheckpoint(fileName, false); len(restarted.Buckets) != 0 {
+		t.Errorf("checkpoint without resume has %d buckets, want none", len(restarted.Buckets))
+	}
+	saved, err := resumed.bucket(job)
+	if err != nil {
+		t.Fatalf("resume checkpoint failed, error: %v", err)
+	}
+	if saved.Marker != "b" || saved.Completed != 1 || len(saved.Failed) != 1 || saved.Failed[0].Key != "a" {
+		t.Fatalf("saved checkpoint is marker %q, completed %d, failed %v, want marker \"b\", completed 1, failed [a]",
+			saved.Marker, saved.Completed, saved.Failed)
+	}
+
+	source := newFakeStore(2)
+	source.put("source", "a", "b", "c", "d", "e")
+	target := newFakeStore(2)
+	target.put("target")
+	result := syncBucketData(source, target, &bucketSyncJob{
+		sourceBucket: "source",
+		targetBucket: "target",
+		checkpoint:   resumed,
+	})
+	if result.err != nil || result.copied != 4 || result.failed != 0 {
+		t.Fatalf("resumed sync copied %d, failed %d, error: %v, want 4 copied", result.copied, result.failed, result.err)
+	}
+	// the failed object is retried and the listing continues after the marker
+	want := map[string]int{"target/a": 1, "target/c": 1, "target/d": 1, "target/e": 1}
+	if !reflect.DeepEqual(target.uploads, want) {
+		t.Errorf("resumed sync uploaded %v, want %v", target.uploads, want)
+	}
+
+	finished, err := loadCheckpoint(fileName, true)
+	if err != nil {
+		t.Fatalf("load finished checkpoint failed, error: %v", err)
+	}
+	if entry, _ := finished.bucket(job); !entry.Finished || entry.Marker != "e" || entry.Completed != 5 || len(entry.Failed) != 0 {
+		t.Errorf("finished checkpoint is %+v, want finished at marker \"e\" with 5 completed objects", entry)
+	}
+}
+
+func TestCheckpointOptions(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "checkpoint.json")
+	cp, _ := loadCheckpoint(fileName, false)
+	entry, _ := cp.bucket(&bucketSyncJob{sourceBucket: "source", targetBucket: "target"})
+	cp.commit(entry, "a", 1)
+
+	tests := []struct {
+		name         string
+		allVersions  bool
+		rewriteRules []string
+		filterRules  []string
+		wantErr      bool
+	}{
+		{name: "same options"},
+		{name: "empty rules", rewriteRules: []string{}},
+		{name: "all versions", allVersions: true, wantErr: true},
+		{name: "rewrite rules", rewriteRules: []string{"s#^a#b#"}, wantErr: true},
+		{name: "filter rules", filterRules: []string{"- *.tmp"}, wantErr: true},
+	}
+	for _, test := range tests {
+		RewriteRules, FilterRules = test.rewriteRules, test.filterRules
+		resumed, err := loadCheckpoint(fileName, true)
+		if err != nil {
+			t.Fatalf("load checkpoint failed, error: %v", err)
+		}
+		entry, err := resumed.bucket(&bucketSyncJob{sourceBucket: "source", targetBucket: "target", allVersions: test.allVersions})
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: resume error = %v, want error: %v", test.name, err, test.wantErr)
+		}
+		if err == nil && entry.Marker != "a" {
+			t.Errorf("%s: resumed marker = %q, want %q", test.name, entry.Marker, "a")
+		}
+	}
+	RewriteRules, FilterRules = nil, nil
+}
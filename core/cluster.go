@@ -0,0 +1,140 @@
+package core
+
+import (
+	"fmt"
+	"github.com/shangjin92/ceph-sync/internal/store"
+	"github.com/sirupsen/logrus"
+	"strings"
+)
+
+// SyncClusterData syncs every bucket of the source cluster to the target bucket
+// of the same name, and returns the error the whole run ends with.
+func SyncClusterData() error {
+	logrus.Info("Begin sync data from source cluster...")
+	defer handleInterrupt()()
+
+	summary := newRunSummary()
+	err := syncClusterData(summary)
+	summary.finish(err)
+
+	logrus.Info("Finished sync data from source cluster...")
+	return err
+}
+
+func syncClusterData(summary *RunSummary) error {
+	if err := validateCompareMode(); err != nil {
+		logrus.Error(err)
+		return configError(err)
+	}
+
+	filter, err := newObjectFilter()
+	if err != nil {
+		logrus.Errorf("load filters failed, error: %v", err)
+		return configError(err)
+	}
+
+	mapper, err := newKeyMapper()
+	if err != nil {
+		logrus.Errorf("load key rewrite rules failed, error: %v", err)
+		return configError(err)
+	}
+
+	switch strings.ToLower(SourceType) {
+	case "ceph", "s3", "oss":
+	default:
+		logrus.Errorf("cluster sync don't support source type: %q", SourceType)
+		return configError(fmt.Errorf("cluster sync don't support source type: %q", SourceType))
+	}
+
+	sourceStoreClient, targetStoreClient, err := newStoreClients()
+	if err != nil {
+		return err
+	}
+	if err = checkAccessSupport(sourceStoreClient, targetStoreClient); err != nil {
+		logrus.Error(err)
+		return configError(err)
+	}
+	if err = checkVersionSupport(sourceStoreClient, targetStoreClient); err != nil {
+		logrus.Error(err)
+		return configError(err)
+	}
+	users, err := newUserMapper()
+	if err != nil {
+		logrus.Errorf("load acl user map failed, error: %v", err)
+		return configError(err)
+	}
+	storageClasses, err := newStorageClassMapper()
+	if err != nil {
+		logrus.Errorf("load storage class map failed, error: %v", err)
+		return configError(err)
+	}
+
+	cp, err := newRunCheckpoint()
+	if err != nil {
+		logrus.Errorf("load checkpoint failed, error: %v", err)
+		return configError(err)
+	}
+
+	failures, err := newFailureManifest()
+	if err != nil {
+		logrus.Error(err)
+		return configError(err)
+	}
+	defer failures.close()
+
+	versions, err := newVersionMap()
+	if err != nil {
+		logrus.Error(err)
+		return configError(err)
+	}
+	defer versions.close()
+
+	var listBucketsResult *store.ListBucketsResult
+	_, err = withRetry("list source buckets", func() (err error) {
+		listBucketsResult, err = sourceStoreClient.ListBuckets()
+		return err
+	})
+	if err != nil {
+		logrus.Errorf("list source buckets failed, error: %v", err)
+		return sourceError(err)
+	}
+	if listBucketsResult == nil || len(listBucketsResult.BucketNames) == 0 {
+		logrus.Info("no bucket found in source cluster, nothing to sync.")
+		return nil
+	}
+
+	var plan *syncPlan
+	if DryRun {
+		plan = newSyncPlan(PlanOutput != "")
+	}
+
+	var results []*bucketSyncResult
+	for _, bucketName := range listBucketsResult.BucketNames {
+		if isInterrupted() {
+			break
+		}
+		logrus.Infof("sync bucket: %s", bucketName)
+		job := &bucketSyncJob{
+			sourceBucket:   bucketName,
+			targetBucket:   bucketName,
+			dryRun:         DryRun,
+			plan:           plan,
+			checkpoint:     cp,
+			failures:       failures,
+			filter:         filter,
+			keyMapper:      mapper,
+			users:          users,
+			storageClasses: storageClasses,
+			allVersions:    AllVersions,
+			versions:       versions,
+		}
+		result := syncBucketData(sourceStoreClient, targetStoreClient, job)
+		summary.addBucket(job, result)
+		results = append(results, result)
+	}
+
+	if plan != nil {
+		finishPlan(plan)
+	}
+	return summary.bucketsError(results)
+}
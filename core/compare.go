@@ -0,0 +1,136 @@
+package core
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"github.com/shangjin92/ceph-sync/internal/store"
+	"io"
+	"strings"
+)
+
+const (
+	CompareNone     = "none"
+	CompareSize     = "size"
+	CompareEtag     = "etag"
+	CompareMtime    = "mtime"
+	CompareChecksum = "checksum"
+)
+
+func validateCompareMode() error {
+	switch CompareMode {
+	case CompareNone, CompareSize, CompareEtag, CompareMtime, CompareChecksum:
+		return nil
+	default:
+		return fmt.Errorf("unknown compare mode: %q, maybe: none/size/etag/mtime/checksum", CompareMode)
+	}
+}
+
+// isMultipartEtag reports whether etag was produced by a multipart upload, in
+// which case it is not the MD5 of the object content.
+func isMultipartEtag(etag string) bool {
+	return strings.Contains(etag, "-")
+}
+
+// isSameSizeAndMtime reports whether the target has the source size and was
+// written after the source was last modified.
+func isSameSizeAndMtime(source, target *store.ObjectInfo) bool {
+	return source.Size == target.Size && !target.LastModified.Before(source.LastModified)
+}
+
+// isSameEtag compares ETags when both are content MD5s. Objects without an ETag,
+// like local files, or uploaded with a different part layout fall back to size
+// and last-modified.
+func isSameEtag(source, target *store.ObjectInfo) bool {
+	if source.Size != target.Size {
+		return false
+	}
+	if source.ETag == "" || target.ETag == "" || isMultipartEtag(source.ETag) != isMultipartEtag(target.ETag) {
+		return isSameSizeAndMtime(source, target)
+	}
+	return strings.EqualFold(source.ETag, target.ETag)
+}
+
+// isSameChecksum compares the MD5 of the source content with the MD5 of the
+// target content. Either content is only read when its ETag is not a plain MD5,
+// as with targets written by a multipart upload.
+func isSameChecksum(sourceClient, targetClient store.Store, job *bucketSyncJob, source, target *store.ObjectInfo) (bool, error) {
+	if source.Size != target.Size {
+		return false, nil
+	}
+
+	targetMd5 := target.ETag
+	if targetMd5 == "" || isMultipartEtag(targetMd5) {
+		var err error
+		targetMd5, err = objectMd5(targetClient, job.targetBucket, target.Key)
+		if err != nil {
+			return false, err
+		}
+	}
+	sourceMd5 := source.ETag
+	if sourceMd5 == "" || isMultipartEtag(sourceMd5) {
+		var err error
+		sourceMd5, err = objectMd5(sourceClient, job.sourceBucket, source.Key)
+		if err != nil {
+			return false, err
+		}
+	}
+	return strings.EqualFold(sourceMd5, targetMd5), nil
+}
+
+func objectMd5(sourceClient store.Store, bucketName, objectName string) (string, error) {
+	objectUrl, urlType, err := sourceClient.GetObjectUrl(bucketName, objectName)
+	if err != nil {
+		return "", err
+	}
+	body, _, err := store.OpenUrlData(urlType, objectUrl)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	hash := md5.New()
+	if _, err = io.Copy(hash, body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// planObject decides what happens to the object of task. The target object is
+// only looked up when a compare mode is set or a plan is made, otherwise the
+// object is always copied and reported as created.
+func planObject(sourceClient, targetClient store.Store, job *bucketSyncJob, task *syncTask) (objectAction, error) {
+	compare := CompareMode != CompareNone && CompareMode != ""
+	if job.targetBucketMissing || (!compare && !job.dryRun) {
+		return actionCreate, nil
+	}
+
+	var target *store.ObjectInfo
+	_, err := withRetry("stat object "+task.targetKey, func() (err error) {
+		target, err = targetClient.StatObject(job.targetBucket, task.targetKey)
+		return err
+	})
+	if err == store.ErrObjectNotFound {
+		return actionCreate, nil
+	}
+	if err != nil {
+		return actionOverwrite, err
+	}
+
+	inSync := false
+	source := &task.object
+	switch CompareMode {
+	case CompareSize:
+		inSync = source.Size == target.Size
+	case CompareEtag:
+		inSync = isSameEtag(source, target)
+	case CompareMtime:
+		inSync = isSameSizeAndMtime(source, target)
+	case CompareChecksum:
+		inSync, err = isSameChecksum(sourceClient, targetClient, job, source, target)
+	}
+	if inSync {
+		return actionSkip, err
+	}
+	return actionOverwrite, err
+}
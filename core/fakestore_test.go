@@ -0,0 +1,142 @@
+package core
+
+import (
+	"github.com/shangjin92/ceph-sync/internal/store"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// fakeStore is an in-memory store.Store listing pageSize objects at a time in
+// key order, like s3 does.
+type fakeStore struct {
+	mutex    sync.Mutex
+	pageSize int
+	buckets  map[string]map[string]store.ObjectInfo
+	// uploads counts the uploads of every "bucket/key"
+	uploads map[string]int
+	deleted []string
+	// uploadHook, when set, is called before an object is written, a returned
+	// error fails the upload
+	uploadHook func(bucketName, objectName string) error
+}
+
+func newFakeStore(pageSize int) *fakeStore {
+	return &fakeStore{
+		pageSize: pageSize,
+		buckets:  make(map[string]map[string]store.ObjectInfo),
+		uploads:  make(map[string]int),
+	}
+}
+
+// put adds objects of one byte per character of their key to a bucket.
+func (fake *fakeStore) put(bucketName string, keys ...string) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	if fake.buckets[bucketName] == nil {
+		fake.buckets[bucketName] = make(map[string]store.ObjectInfo)
+	}
+	for _, key := range keys {
+		fake.buckets[bucketName][key] = store.ObjectInfo{Key: key, Size: int64(len(key))}
+	}
+}
+
+// keys returns the sorted keys of a bucket.
+func (fake *fakeStore) keys(bucketName string) []string {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	return fake.sortedKeys(bucketName)
+}
+
+// sortedKeys is keys for a caller holding the mutex.
+func (fake *fakeStore) sortedKeys(bucketName string) []string {
+	var keys []string
+	for key := range fake.buckets[bucketName] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (fake *fakeStore) ListBuckets() (*store.ListBucketsResult, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	result := &store.ListBucketsResult{}
+	for bucketName := range fake.buckets {
+		result.BucketNames = append(result.BucketNames, bucketName)
+	}
+	sort.Strings(result.BucketNames)
+	return result, nil
+}
+
+func (fake *fakeStore) CheckBucketExist(bucketName string) (bool, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	_, ok := fake.buckets[bucketName]
+	return ok, nil
+}
+
+func (fake *fakeStore) CreateBucket(bucketName string) error {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	fake.buckets[bucketName] = make(map[string]store.ObjectInfo)
+	return nil
+}
+
+// UploadFile writes the object behind an url of GetObjectUrl, which is the
+// source key.
+func (fake *fakeStore) UploadFile(urlType store.UrlType, urlStr, dstBucketName, dstObjectName string, opts *store.UploadOptions) error {
+	if fake.uploadHook != nil {
+		if err := fake.uploadHook(dstBucketName, dstObjectName); err != nil {
+			return err
+		}
+	}
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	fake.uploads[dstBucketName+"/"+dstObjectName]++
+	fake.buckets[dstBucketName][dstObjectName] = store.ObjectInfo{Key: dstObjectName, Size: int64(len(urlStr))}
+	return nil
+}
+
+func (fake *fakeStore) GetObjectUrl(bucketName, objectName string) (string, store.UrlType, error) {
+	return objectName, store.HttpUrl, nil
+}
+
+func (fake *fakeStore) ListObjects(bucketName, marker, prefix string) (*store.ListObjectsResult, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	var objects []store.ObjectInfo
+	for _, key := range fake.sortedKeys(bucketName) {
+		if key > marker && strings.HasPrefix(key, prefix) {
+			objects = append(objects, fake.buckets[bucketName][key])
+		}
+	}
+
+	suspend := len(objects) <= fake.pageSize
+	if !suspend {
+		objects = objects[:fake.pageSize]
+	}
+	nextMarker := ""
+	if len(objects) > 0 {
+		nextMarker = objects[len(objects)-1].Key
+	}
+	return &store.ListObjectsResult{Objects: objects, Suspend: &suspend, NextMarker: &nextMarker}, nil
+}
+
+func (fake *fakeStore) StatObject(bucketName, objectName string) (*store.ObjectInfo, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	object, ok := fake.buckets[bucketName][objectName]
+	if !ok {
+		return nil, store.ErrObjectNotFound
+	}
+	return &object, nil
+}
+
+func (fake *fakeStore) DeleteObject(bucketName, objectName string) error {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	delete(fake.buckets[bucketName], objectName)
+	fake.deleted = append(fake.deleted, objectName)
+	return nil
+}
@@ -0,0 +1,295 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/shangjin92/ceph-sync/internal/store"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// regexPrefix marks a filter pattern as a regular expression matched against
+// the whole key instead of a glob.
+const regexPrefix = "regex:"
+
+// filterRule includes or excludes the keys its pattern matches.
+type filterRule struct {
+	include bool
+	pattern string
+	regexp  *regexp.Regexp
+}
+
+// objectFilter selects the source objects to sync. Rules are checked in order
+// and the first one matching a key decides, keys no rule matches are synced.
+// Objects must also be within the size and modification time limits.
+type objectFilter struct {
+	rules []*filterRule
+
+	// minSize and maxSize are -1 when not set
+	minSize        int64
+	maxSize        int64
+	modifiedAfter  time.Time
+	modifiedBefore time.Time
+}
+
+// newObjectFilter builds the filter of FilterRules and the size and modification
+// time limits, it returns nil when there is nothing to filter. Rules are given
+// as "+ pattern" to include, "- pattern" to exclude or ". file" to read more
+// rules from a file at that position.
+func newObjectFilter() (*objectFilter, error) {
+	filter := &objectFilter{minSize: -1, maxSize: -1}
+	for _, rule := range FilterRules {
+		if err := filter.addRule(rule, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	if filter.minSize, err = parseSizeLimit("--min-size", MinSize); err != nil {
+		return nil, err
+	}
+	if filter.maxSize, err = parseSizeLimit("--max-size", MaxSize); err != nil {
+		return nil, err
+	}
+	if filter.modifiedAfter, err = parseTimeLimit("--modified-after", ModifiedAfter); err != nil {
+		return nil, err
+	}
+	if filter.modifiedBefore, err = parseTimeLimit("--modified-before", ModifiedBefore); err != nil {
+		return nil, err
+	}
+
+	if len(filter.rules) == 0 && filter.minSize < 0 && filter.maxSize < 0 &&
+		filter.modifiedAfter.IsZero() && filter.modifiedBefore.IsZero() {
+		return nil, nil
+	}
+	return filter, nil
+}
+
+func (filter *objectFilter) addRule(rule, fileName string) error {
+	if len(rule) < 2 || rule[1] != ' ' {
+		return fmt.Errorf("invalid filter rule: %q", rule)
+	}
+	pattern := rule[2:]
+	switch rule[0] {
+	case '+', '-':
+		re, err := compileFilterPattern(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid filter pattern: %q, error: %v", pattern, err)
+		}
+		filter.rules = append(filter.rules, &filterRule{include: rule[0] == '+', pattern: pattern, regexp: re})
+		return nil
+	case '.':
+		if fileName != "" {
+			return fmt.Errorf("filter file: %s can't read filter file: %s", fileName, pattern)
+		}
+		return filter.readRules(pattern)
+	default:
+		return fmt.Errorf("invalid filter rule: %q", rule)
+	}
+}
+
+// readRules adds the rules of a filter file, one per line. Empty lines and lines
+// starting with "#" or ";" are ignored.
+func (filter *objectFilter) readRules(fileName string) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if err = filter.addRule(line, fileName); err != nil {
+			return fmt.Errorf("filter file: %s line %d: %v", fileName, lineNumber, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// includes reports whether the object passes the filter, a nil filter passes
+// every object.
+func (filter *objectFilter) includes(object store.ObjectInfo) bool {
+	if filter == nil {
+		return true
+	}
+	if filter.minSize >= 0 && object.Size < filter.minSize {
+		return false
+	}
+	if filter.maxSize >= 0 && object.Size > filter.maxSize {
+		return false
+	}
+	if !filter.modifiedAfter.IsZero() && object.LastModified.Before(filter.modifiedAfter) {
+		return false
+	}
+	if !filter.modifiedBefore.IsZero() && !object.LastModified.Before(filter.modifiedBefore) {
+		return false
+	}
+
+	for _, rule := range filter.rules {
+		if rule.regexp.MatchString(object.Key) {
+			return rule.include
+		}
+	}
+	return true
+}
+
+// includesVersion is includes for an object version. Delete markers have no size
+// and the time of the delete, so only the rules select them: a marker dropped by
+// the size or time limits would leave the deleted object current on the target.
+func (filter *objectFilter) includesVersion(version store.ObjectVersion) bool {
+	if version.DeleteMarker {
+		return !filter.excludes(version.Key)
+	}
+	return filter.includes(version.ObjectInfo)
+}
+
+// excludes reports whether a rule excludes key. Size and time limits are left
+// out, as the key may not belong to a source object at all.
+func (filter *objectFilter) excludes(key string) bool {
+	if filter == nil {
+		return false
+	}
+	for _, rule := range filter.rules {
+		if rule.regexp.MatchString(key) {
+			return !rule.include
+		}
+	}
+	return false
+}
+
+// sizeUnits are the multipliers of the size suffixes, powers of 1024 like du and
+// rsync use.
+var sizeUnits = map[string]float64{
+	"":  1,
+	"b": 1,
+	"k": 1 << 10, "kb": 1 << 10, "kib": 1 << 10,
+	"m": 1 << 20, "mb": 1 << 20, "mib": 1 << 20,
+	"g": 1 << 30, "gb": 1 << 30, "gib": 1 << 30,
+	"t": 1 << 40, "tb": 1 << 40, "tib": 1 << 40,
+	"p": 1 << 50, "pb": 1 << 50, "pib": 1 << 50,
+}
+
+// parseSizeLimit parses a size like 1024, 512K, 1.5G or 50GB, it returns -1 for
+// an empty value.
+func parseSizeLimit(name, value string) (int64, error) {
+	if value == "" {
+		return -1, nil
+	}
+	lower := strings.ToLower(strings.TrimSpace(value))
+	number := strings.TrimRightFunc(lower, func(r rune) bool {
+		return r >= 'a' && r <= 'z'
+	})
+	unit, ok := sizeUnits[strings.TrimSpace(lower[len(number):])]
+	size, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if !ok || err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid %s: %q, expect a size like 1024, 512K, 1.5G or 50GB", name, value)
+	}
+	return int64(size * unit), nil
+}
+
+// timeLayouts are the accepted layouts of absolute modification time limits,
+// times without a zone are local times.
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// parseTimeLimit parses a modification time limit, either a time like
+// 2021-08-01 or 2021-08-01T12:00:00Z, or an age like 36h or 7d before now. It
+// returns the zero time for an empty value.
+func parseTimeLimit(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if strings.HasSuffix(value, "d") {
+		if days, err := strconv.ParseFloat(strings.TrimSuffix(value, "d"), 64); err == nil && days >= 0 {
+			return time.Now().Add(-time.Duration(days * float64(24*time.Hour))), nil
+		}
+	}
+	if age, err := time.ParseDuration(value); err == nil && age >= 0 {
+		return time.Now().Add(-age), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid %s: %q, expect a time like 2021-08-01 or 2021-08-01T12:00:00Z, or an age like 36h or 7d", name, value)
+}
+
+// compileFilterPattern turns a pattern into the regular expression matching the
+// keys it selects. Like rsync, a glob without "/" matches any part of the key
+// between slashes, a glob with "/" matches whole trailing parts of the key, and
+// a glob starting with "/" matches from the start of the key. Since a glob also
+// matches the keys below a "directory" it matches, "_temporary" or "_temporary/"
+// select every key under a _temporary part. In a glob "*" and "?" don't match
+// "/", "**" matches anything.
+func compileFilterPattern(pattern string) (*regexp.Regexp, error) {
+	if strings.HasPrefix(pattern, regexPrefix) {
+		return regexp.Compile(strings.TrimPrefix(pattern, regexPrefix))
+	}
+	if pattern == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+
+	expr := "(^|/)"
+	if strings.HasPrefix(pattern, "/") {
+		expr = "^"
+		pattern = strings.TrimPrefix(pattern, "/")
+	}
+	end := "(/|$)"
+	if strings.HasSuffix(pattern, "/") {
+		end = "/"
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	body, err := globToRegexp(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return regexp.Compile(expr + body + end)
+}
+
+func globToRegexp(glob string) (string, error) {
+	var expr strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch glob[i] {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				expr.WriteString(".*")
+				i++
+			} else {
+				expr.WriteString("[^/]*")
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("missing ] in %q", glob)
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+			}
+			expr.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			expr.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	return expr.String(), nil
+}
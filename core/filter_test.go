@@ -0,0 +1,171 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompileFilterPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		match   bool
+	}{
+		{"*.tmp", "a.tmp", true},
+		{"*.tmp", "dir/a.tmp", true},
+		{"*.tmp", "a.tmp/b.txt", true},
+		{"*.tmp", "a.tmpx", false},
+		{"*.tmp", "dir/a.txt", false},
+		{".DS_Store", "photos/.DS_Store", true},
+		{".DS_Store", "photos/x.DS_Store", false},
+		{"_temporary/", "out/_temporary/part-0", true},
+		{"_temporary/", "out/_temporary", false},
+		{"_temporary", "out/_temporary", true},
+		{"logs/*.tmp", "logs/a.tmp", true},
+		{"logs/*.tmp", "app/logs/a.tmp", true},
+		{"logs/*.tmp", "logs/sub/a.tmp", false},
+		{"logs/**.tmp", "logs/sub/a.tmp", true},
+		{"/logs", "logs/a.txt", true},
+		{"/logs", "app/logs/a.txt", false},
+		{"a?c", "abc", true},
+		{"a?c", "a/c", false},
+		{"[a-c]x", "bx", true},
+		{"[a-c]x", "dx", false},
+		{"[!a-c]x", "dx", true},
+		{"[!a-c]x", "bx", false},
+		{`\*`, "*", true},
+		{`\*`, "a", false},
+		{"a.b", "axb", false},
+		{"regex:^logs/.*\\.gz$", "logs/a.gz", true},
+		{"regex:\\.gz$", "logs/a.gz", true},
+		{"regex:^logs/", "app/logs/a.gz", false},
+	}
+
+	for _, test := range tests {
+		re, err := compileFilterPattern(test.pattern)
+		if err != nil {
+			t.Errorf("compileFilterPattern(%q) failed, error: %v", test.pattern, err)
+			continue
+		}
+		if match := re.MatchString(test.key); match != test.match {
+			t.Errorf("pattern %q matches %q = %v, want %v", test.pattern, test.key, match, test.match)
+		}
+	}
+}
+
+func TestCompileFilterPatternError(t *testing.T) {
+	for _, pattern := range []string{"", "[a-c", "regex:(", "regex:[a-"} {
+		if _, err := compileFilterPattern(pattern); err == nil {
+			t.Errorf("compileFilterPattern(%q) succeeded, want an error", pattern)
+		}
+	}
+}
+
+func TestObjectFilterExcludes(t *testing.T) {
+	filter := &objectFilter{minSize: -1, maxSize: -1}
+	for _, rule := range []string{"+ keep.tmp", "- *.tmp", "+ *"} {
+		if err := filter.addRule(rule, ""); err != nil {
+			t.Fatalf("add rule %q failed, error: %v", rule, err)
+		}
+	}
+
+	tests := []struct {
+		key      string
+		excludes bool
+	}{
+		{"a.tmp", true},
+		{"dir/keep.tmp", false},
+		{"a.txt", false},
+	}
+	for _, test := range tests {
+		if excludes := filter.excludes(test.key); excludes != test.excludes {
+			t.Errorf("excludes(%q) = %v, want %v", test.key, excludes, test.excludes)
+		}
+	}
+	if (*objectFilter)(nil).excludes("a.tmp") {
+		t.Errorf("nil filter excludes a key")
+	}
+}
+
+func TestParseSizeLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		size    int64
+		wantErr bool
+	}{
+		{"", -1, false},
+		{"0", 0, false},
+		{"1024", 1024, false},
+		{"512K", 512 << 10, false},
+		{"512k", 512 << 10, false},
+		{"512KiB", 512 << 10, false},
+		{"1.5G", 3 << 29, false},
+		{"50GB", 50 << 30, false},
+		{"2 M", 2 << 20, false},
+		{"1T", 1 << 40, false},
+		{"10b", 10, false},
+		{"-1", 0, true},
+		{"1X", 0, true},
+		{"K", 0, true},
+		{"abc", 0, true},
+		{"1.2.3M", 0, true},
+	}
+
+	for _, test := range tests {
+		size, err := parseSizeLimit("--max-size", test.value)
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseSizeLimit(%q) error = %v, want error: %v", test.value, err, test.wantErr)
+			continue
+		}
+		if !test.wantErr && size != test.size {
+			t.Errorf("parseSizeLimit(%q) = %d, want %d", test.value, size, test.size)
+		}
+	}
+}
+
+func TestParseTimeLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		time    time.Time
+		age     time.Duration
+		wantErr bool
+	}{
+		{value: ""},
+		{value: "2021-08-01", time: time.Date(2021, 8, 1, 0, 0, 0, 0, time.Local)},
+		{value: "2021-08-01 12:30:00", time: time.Date(2021, 8, 1, 12, 30, 0, 0, time.Local)},
+		{value: "2021-08-01T12:30:00", time: time.Date(2021, 8, 1, 12, 30, 0, 0, time.Local)},
+		{value: "2021-08-01T12:30:00Z", time: time.Date(2021, 8, 1, 12, 30, 0, 0, time.UTC)},
+		{value: "2021-08-01T12:30:00+08:00", time: time.Date(2021, 8, 1, 4, 30, 0, 0, time.UTC)},
+		{value: "36h", age: 36 * time.Hour},
+		{value: "90m", age: 90 * time.Minute},
+		{value: "7d", age: 7 * 24 * time.Hour},
+		{value: "1.5d", age: 36 * time.Hour},
+		{value: "-1d", wantErr: true},
+		{value: "-1h", wantErr: true},
+		{value: "2021-13-01", wantErr: true},
+		{value: "yesterday", wantErr: true},
+		{value: "7w", wantErr: true},
+	}
+
+	for _, test := range tests {
+		limit, err := parseTimeLimit("--modified-after", test.value)
+		now := time.Now()
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseTimeLimit(%q) error = %v, want error: %v", test.value, err, test.wantErr)
+			continue
+		}
+		if test.wantErr {
+			continue
+		}
+		if test.age == 0 {
+			if !limit.Equal(test.time) {
+				t.Errorf("parseTimeLimit(%q) = %v, want %v", test.value, limit, test.time)
+			}
+			continue
+		}
+		// an age is relative to the time the limit is parsed
+		if age := now.Sub(limit); age < test.age || age > test.age+time.Minute {
+			t.Errorf("parseTimeLimit(%q) is %v before now, want %v", test.value, age, test.age)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"errors"
+	"github.com/sirupsen/logrus"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// interrupted is closed when the run is interrupted. Listings stop submitting
+// objects, and the transfer pools drop their queued objects once the ones being
+// copied are done, so the checkpoint is left at the last completed page.
+var interrupted = make(chan struct{})
+
+// errInterrupted ends a bucket sync that stopped because the run was interrupted.
+var errInterrupted error = &SyncError{ExitCode: ExitInterrupted, Err: errors.New("sync interrupted")}
+
+// isInterrupted reports whether the run has been interrupted.
+func isInterrupted() bool {
+	select {
+	case <-interrupted:
+		return true
+	default:
+		return false
+	}
+}
+
+// handleInterrupt closes interrupted on the first SIGINT or SIGTERM, a second
+// one exits at once. The returned function stops handling the signals.
+func handleInterrupt() func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-signals:
+		case <-done:
+			return
+		}
+		logrus.Warn("interrupted, finish the objects being copied, interrupt again to exit at once")
+		close(interrupted)
+		select {
+		case <-signals:
+			logrus.Error("interrupted again, exit at once")
+			os.Exit(ExitInterrupted)
+		case <-done:
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
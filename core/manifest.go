@@ -0,0 +1,117 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"os"
+	"sync"
+	"time"
+)
+
+// manifestEntry is one line of a failure manifest, an object that could not be
+// copied. VersionID is the source version that failed with --all-versions.
+// TargetKey is empty when the key could not be mapped to a target key, retry
+// doesn't copy those objects.
+type manifestEntry struct {
+	SourceBucket string    `json:"source_bucket"`
+	Key          string    `json:"key"`
+	TargetBucket string    `json:"target_bucket"`
+	TargetKey    string    `json:"target_key"`
+	VersionID    string    `json:"version_id,omitempty"`
+	Error        string    `json:"error"`
+	Attempts     int       `json:"attempts"`
+	Time         time.Time `json:"time"`
+}
+
+// failureManifest writes every object that failed in a run to a jsonl file.
+type failureManifest struct {
+	lock     sync.Mutex
+	fileName string
+	file     *os.File
+	writer   *bufio.Writer
+	count    int64
+}
+
+// newFailureManifest creates the failure manifest of this run, replacing the
+// one of an earlier run. It is nil when disabled or nothing is copied because
+// of a dry run.
+func newFailureManifest() (*failureManifest, error) {
+	if FailureManifest == "" || DryRun {
+		return nil, nil
+	}
+
+	file, err := os.Create(FailureManifest)
+	if err != nil {
+		return nil, fmt.Errorf("create failure manifest: %s failed, error: %v", FailureManifest, err)
+	}
+	return &failureManifest{
+		fileName: FailureManifest,
+		file:     file,
+		writer:   bufio.NewWriter(file),
+	}, nil
+}
+
+func (manifest *failureManifest) add(entry *manifestEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		logrus.Errorf("marshal failure manifest entry failed, error: %v", err)
+		return
+	}
+
+	manifest.lock.Lock()
+	defer manifest.lock.Unlock()
+	manifest.count++
+	if _, err = manifest.writer.Write(append(data, '\n')); err == nil {
+		// flush every entry, so the manifest is complete even if the run crashes
+		err = manifest.writer.Flush()
+	}
+	if err != nil {
+		logrus.Errorf("write failure manifest: %s failed, error: %v", manifest.fileName, err)
+	}
+}
+
+// close closes the manifest, and removes it when no object failed.
+func (manifest *failureManifest) close() {
+	if manifest == nil {
+		return
+	}
+
+	manifest.lock.Lock()
+	defer manifest.lock.Unlock()
+	if err := manifest.file.Close(); err != nil {
+		logrus.Errorf("close failure manifest: %s failed, error: %v", manifest.fileName, err)
+	}
+	if manifest.count == 0 {
+		_ = os.Remove(manifest.fileName)
+		return
+	}
+	logrus.Warnf("%d failed objects written to failure manifest: %s", manifest.count, manifest.fileName)
+}
+
+// readFailureManifest reads every entry of a failure manifest.
+func readFailureManifest(fileName string) ([]*manifestEntry, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []*manifestEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		entry := &manifestEntry{}
+		if err = json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return nil, fmt.Errorf("parse failure manifest: %s line %d failed, error: %v", fileName, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
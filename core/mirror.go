@@ -0,0 +1,167 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"github.com/shangjin92/ceph-sync/internal/store"
+	"github.com/sirupsen/logrus"
+	"strings"
+)
+
+// checkMirrorSupport fails when --delete is combined with options it can't
+// mirror. Extraneous objects are found by merging the source and the target
+// listings, which needs target keys in the order of their source keys, and
+// rewritten keys aren't.
+func checkMirrorSupport() error {
+	if !MirrorDelete && !DeleteDryRun {
+		return nil
+	}
+	if len(RewriteRules) > 0 || KeyTemplate != "" {
+		return errors.New("--delete can't be used with --rewrite or --key-template, rewritten keys don't keep the listing order")
+	}
+	return nil
+}
+
+// objectLister walks a bucket listing one object at a time, the next page is
+// only listed once the current one is used up.
+type objectLister struct {
+	client     store.Store
+	bucketName string
+	prefix     string
+	marker     string
+	objects    []store.ObjectInfo
+	done       bool
+}
+
+// next returns the next object of the listing, or nil at its end.
+func (lister *objectLister) next() (*store.ObjectInfo, error) {
+	for len(lister.objects) == 0 {
+		if lister.done {
+			return nil, nil
+		}
+		listObjectResult, err := listObjects(lister.client, lister.bucketName, lister.marker, lister.prefix)
+		if err != nil {
+			return nil, err
+		}
+		lister.objects = listObjectResult.Objects
+		lister.done = *listObjectResult.Suspend
+		lister.marker = *listObjectResult.NextMarker
+	}
+	object := &lister.objects[0]
+	lister.objects = lister.objects[1:]
+	return object, nil
+}
+
+// nextTargetKey returns the target key of the next source object that can be
+// mapped, ok is false at the end of the source listing.
+func nextTargetKey(source *objectLister, job *bucketSyncJob) (targetKey string, ok bool, err error) {
+	for {
+		object, err := source.next()
+		if err != nil || object == nil {
+			return "", false, err
+		}
+		// objects that can't be mapped failed and have no target key
+		if targetKey, err = job.targetObjectName(object.Key); err == nil {
+			return targetKey, true, nil
+		}
+	}
+}
+
+// listExtraneousObjects lists the target bucket under the target prefix and
+// returns the objects no source object is mapped to. Without rewrite rules the
+// target keys of the source listing are in order, so both listings are merged
+// page by page rather than keeping every source key in memory. Source objects
+// the filters don't select are kept like copied ones.
+//
+// Like rsync, objects the filter rules exclude are protected, the rules are
+// matched against the key without the target prefix. The listing stops once it
+// found more than limit objects, a negative limit lists them all.
+func listExtraneousObjects(sourceClient, targetClient store.Store, job *bucketSyncJob, limit int) ([]store.ObjectInfo, error) {
+	// a prefix like backup lists backup/ only, not the objects of backup2/
+	listPrefix := job.targetObjectPrefix
+	if listPrefix != "" && !strings.HasSuffix(listPrefix, "/") {
+		listPrefix += "/"
+	}
+	source := &objectLister{client: sourceClient, bucketName: job.sourceBucket, prefix: job.sourceObjectPrefix}
+	target := &objectLister{client: targetClient, bucketName: job.targetBucket, prefix: listPrefix}
+
+	sourceKey, sourceOk, err := nextTargetKey(source, job)
+	if err != nil {
+		logrus.Errorf("list objects failed, source cluster bucket: %s, error: %v", job.sourceBucket, err)
+		return nil, sourceError(err)
+	}
+	var extraneous []store.ObjectInfo
+	for {
+		object, err := target.next()
+		if err != nil {
+			logrus.Errorf("list target objects failed, bucket: %s, error: %v", job.targetBucket, err)
+			return nil, targetError(err)
+		}
+		if object == nil {
+			return extraneous, nil
+		}
+
+		for sourceOk && sourceKey < object.Key {
+			if sourceKey, sourceOk, err = nextTargetKey(source, job); err != nil {
+				logrus.Errorf("list objects failed, source cluster bucket: %s, error: %v", job.sourceBucket, err)
+				return nil, sourceError(err)
+			}
+		}
+		if sourceOk && sourceKey == object.Key {
+			continue
+		}
+		if job.filter.excludes(strings.TrimPrefix(object.Key, job.targetObjectPrefix)) {
+			logrus.Infof("excluded object kept, bucket: %s, name: %s", job.targetBucket, object.Key)
+			continue
+		}
+		extraneous = append(extraneous, *object)
+		if limit >= 0 && len(extraneous) > limit {
+			return extraneous, nil
+		}
+	}
+}
+
+// deleteExtraneousObjects removes the target objects that no longer exist at the
+// source. Nothing is removed when there are more of them than MaxDelete allows.
+func deleteExtraneousObjects(sourceClient, targetClient store.Store, job *bucketSyncJob, result *bucketSyncResult) error {
+	extraneous, err := listExtraneousObjects(sourceClient, targetClient, job, MaxDelete)
+	if err != nil {
+		return err
+	}
+	if len(extraneous) == 0 {
+		logrus.Infof("no extraneous object in target bucket: %s", job.targetBucket)
+		return nil
+	}
+
+	if MaxDelete >= 0 && len(extraneous) > MaxDelete {
+		for _, object := range extraneous {
+			logrus.Warnf("extraneous object kept, bucket: %s, name: %s", job.targetBucket, object.Key)
+		}
+		return fmt.Errorf("more objects to delete in bucket: %s than --max-delete %d allows, nothing deleted",
+			job.targetBucket, MaxDelete)
+	}
+
+	for _, object := range extraneous {
+		if job.plan != nil {
+			job.plan.add(&planEntry{
+				Action:       actionDelete,
+				TargetBucket: job.targetBucket,
+				TargetKey:    object.Key,
+				Size:         object.Size,
+			})
+		}
+		if DeleteDryRun || job.dryRun {
+			logrus.Infof("would delete object, bucket: %s, name: %s", job.targetBucket, object.Key)
+			continue
+		}
+		_, err = withRetry("delete object "+object.Key, func() error {
+			return targetClient.DeleteObject(job.targetBucket, object.Key)
+		})
+		if err != nil {
+			result.failed++
+			continue
+		}
+		result.deleted++
+	}
+	return nil
+}
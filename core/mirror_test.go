@@ -0,0 +1,131 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMirrorDelete(t *testing.T) {
+	tests := []struct {
+		name         string
+		sourcePrefix string
+		targetPrefix string
+		filter       *objectFilter
+		rules        []string
+		maxDelete    int
+		source       []string
+		target       []string
+		wantDeleted  []string
+		wantErr      bool
+	}{
+		{
+			name:        "objects missing at the source",
+			maxDelete:   -1,
+			source:      []string{"a", "b", "d", "f"},
+			target:      []string{"a", "b", "c", "d", "e", "g", "h"},
+			wantDeleted: []string{"c", "e", "g", "h"},
+		},
+		{
+			name:        "nothing to delete",
+			maxDelete:   -1,
+			source:      []string{"a", "b", "c"},
+			target:      []string{"a", "c"},
+			wantDeleted: nil,
+		},
+		{
+			name:        "excluded objects are protected",
+			filter:      &objectFilter{minSize: -1, maxSize: -1},
+			rules:       []string{"- *.tmp"},
+			maxDelete:   -1,
+			source:      []string{"a", "b.tmp"},
+			target:      []string{"a", "c", "c.tmp", "d/e.tmp"},
+			wantDeleted: []string{"c"},
+		},
+		{
+			// objects are one byte per character of their key, so a is too small
+			name:        "source objects the size filter skips are kept",
+			filter:      &objectFilter{minSize: 2, maxSize: -1},
+			maxDelete:   -1,
+			source:      []string{"a", "bb"},
+			target:      []string{"a", "bb", "c"},
+			wantDeleted: []string{"c"},
+		},
+		{
+			name:         "target prefix is a directory",
+			sourcePrefix: "logs/",
+			targetPrefix: "backup/",
+			maxDelete:    -1,
+			source:       []string{"logs/a", "logs/b", "other/c"},
+			target:       []string{"backup/a", "backup/c", "backup2/d", "c"},
+			wantDeleted:  []string{"backup/c"},
+		},
+		{
+			name:        "max delete stops deletion",
+			maxDelete:   2,
+			source:      []string{"a"},
+			target:      []string{"a", "b", "c", "d"},
+			wantDeleted: nil,
+			wantErr:     true,
+		},
+		{
+			name:        "max delete allows deletion",
+			maxDelete:   2,
+			source:      []string{"a"},
+			target:      []string{"a", "b", "c"},
+			wantDeleted: []string{"b", "c"},
+		},
+	}
+
+	for _, test := range tests {
+		for _, rule := range test.rules {
+			if err := test.filter.addRule(rule, ""); err != nil {
+				t.Fatalf("add rule %q failed, error: %v", rule, err)
+			}
+		}
+		// pages of two objects, so the listings are merged across pages
+		source := newFakeStore(2)
+		source.put("source", test.source...)
+		target := newFakeStore(2)
+		target.put("target", test.target...)
+
+		MaxDelete = test.maxDelete
+		result := syncBucketData(source, target, &bucketSyncJob{
+			sourceBucket:       "source",
+			sourceObjectPrefix: test.sourcePrefix,
+			targetBucket:       "target",
+			targetObjectPrefix: test.targetPrefix,
+			stripSourcePrefix:  true,
+			mirror:             true,
+			filter:             test.filter,
+		})
+		if (result.err != nil) != test.wantErr {
+			t.Errorf("%s: sync error = %v, want error: %v", test.name, result.err, test.wantErr)
+		}
+		if !reflect.DeepEqual(target.deleted, test.wantDeleted) {
+			t.Errorf("%s: deleted = %v, want %v", test.name, target.deleted, test.wantDeleted)
+		}
+		if result.deleted != int64(len(test.wantDeleted)) {
+			t.Errorf("%s: deleted count = %d, want %d", test.name, result.deleted, len(test.wantDeleted))
+		}
+	}
+	MaxDelete = 0
+}
+
+func TestCheckMirrorSupport(t *testing.T) {
+	MirrorDelete = true
+	defer func() {
+		MirrorDelete, RewriteRules, KeyTemplate = false, nil, ""
+	}()
+
+	if err := checkMirrorSupport(); err != nil {
+		t.Errorf("--delete without rewrite rules failed, error: %v", err)
+	}
+	RewriteRules = []string{"s#^a#b#"}
+	if err := checkMirrorSupport(); err == nil {
+		t.Error("--delete with --rewrite succeeded, want an error")
+	}
+	RewriteRules, KeyTemplate = nil, "{{.Name}}"
+	if err := checkMirrorSupport(); err == nil {
+		t.Error("--delete with --key-template succeeded, want an error")
+	}
+}
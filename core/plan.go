@@ -0,0 +1,103 @@
+package core
+
+import (
+	"encoding/json"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"sync"
+)
+
+// objectAction is what a sync does with a single object.
+type objectAction string
+
+const (
+	actionCreate    objectAction = "create"
+	actionOverwrite objectAction = "overwrite"
+	actionSkip      objectAction = "skip"
+	actionDelete    objectAction = "delete"
+)
+
+type planEntry struct {
+	Action          objectAction `json:"action"`
+	SourceBucket    string       `json:"source_bucket,omitempty"`
+	SourceKey       string       `json:"source_key,omitempty"`
+	SourceVersionID string       `json:"source_version_id,omitempty"`
+	TargetBucket    string       `json:"target_bucket"`
+	TargetKey       string       `json:"target_key"`
+	Size            int64        `json:"size"`
+}
+
+type planTotal struct {
+	Objects int64 `json:"objects"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// syncPlan collects what a dry run would do to every object.
+type syncPlan struct {
+	lock    sync.Mutex
+	keep    bool
+	Entries []*planEntry                `json:"entries"`
+	Totals  map[objectAction]*planTotal `json:"totals"`
+	Listed  planTotal                   `json:"listed"`
+}
+
+// newSyncPlan creates an empty plan, entries are only kept in memory when
+// they have to be written to a plan file.
+func newSyncPlan(keepEntries bool) *syncPlan {
+	return &syncPlan{
+		keep: keepEntries,
+		Totals: map[objectAction]*planTotal{
+			actionCreate:    {},
+			actionOverwrite: {},
+			actionSkip:      {},
+			actionDelete:    {},
+		},
+	}
+}
+
+func (plan *syncPlan) add(entry *planEntry) {
+	logrus.Infof("plan: %s %s/%s (%d bytes)", entry.Action, entry.TargetBucket, entry.TargetKey, entry.Size)
+
+	plan.lock.Lock()
+	defer plan.lock.Unlock()
+	if plan.keep {
+		plan.Entries = append(plan.Entries, entry)
+	}
+	total := plan.Totals[entry.Action]
+	total.Objects++
+	total.Bytes += entry.Size
+	if entry.Action != actionDelete {
+		plan.Listed.Objects++
+		plan.Listed.Bytes += entry.Size
+	}
+}
+
+func (plan *syncPlan) print() {
+	logrus.Infof("plan summary, source objects: %d (%d bytes)", plan.Listed.Objects, plan.Listed.Bytes)
+	for _, action := range []objectAction{actionCreate, actionOverwrite, actionSkip, actionDelete} {
+		total := plan.Totals[action]
+		logrus.Infof("  %s: %d objects (%d bytes)", action, total.Objects, total.Bytes)
+	}
+}
+
+func (plan *syncPlan) write(fileName string) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, data, 0644)
+}
+
+// finishPlan prints the plan summary and writes the plan file when one is asked for.
+func finishPlan(plan *syncPlan) {
+	plan.print()
+	if PlanOutput == "" {
+		return
+	}
+	err := plan.write(PlanOutput)
+	if err != nil {
+		logrus.Errorf("write plan file failed, file: %s, error: %v", PlanOutput, err)
+		return
+	}
+	logrus.Infof("plan written to %s", PlanOutput)
+}
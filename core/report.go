@@ -0,0 +1,202 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"time"
+)
+
+// Exit codes of the sync commands.
+const (
+	ExitSuccess           = 0
+	ExitPartialFailure    = 1
+	ExitConfigError       = 2
+	ExitSourceUnreachable = 3
+	ExitTargetUnreachable = 4
+	// ExitInterrupted is the exit code of shells for a process ended by SIGINT
+	ExitInterrupted = 130
+)
+
+// SyncError is an error that ends a run, ExitCode is the exit code of the process for it.
+type SyncError struct {
+	ExitCode int
+	Err      error
+}
+
+func (e *SyncError) Error() string {
+	return e.Err.Error()
+}
+
+func configError(err error) error {
+	return &SyncError{ExitCode: ExitConfigError, Err: err}
+}
+
+func sourceError(err error) error {
+	return &SyncError{ExitCode: ExitSourceUnreachable, Err: err}
+}
+
+func targetError(err error) error {
+	return &SyncError{ExitCode: ExitTargetUnreachable, Err: err}
+}
+
+// ExitCode returns the process exit code for the error a command returns, like
+// SyncClusterData, SyncClusterBucketData, SyncBucketConfig or RetryFailedObjects.
+// A run without error succeeded, a SyncError carries the exit code of the run,
+// and any other error means some objects or configurations were not synced.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitSuccess
+	}
+	if syncErr, ok := err.(*SyncError); ok {
+		return syncErr.ExitCode
+	}
+	return ExitPartialFailure
+}
+
+// BucketSummary is the outcome of the sync of one bucket.
+type BucketSummary struct {
+	SourceBucket string `json:"source_bucket"`
+	TargetBucket string `json:"target_bucket"`
+	Listed       int64  `json:"listed"`
+	Filtered     int64  `json:"filtered"`
+	Copied       int64  `json:"copied"`
+	Skipped      int64  `json:"skipped"`
+	Failed       int64  `json:"failed"`
+	Deleted      int64  `json:"deleted"`
+	Bytes        int64  `json:"bytes"`
+	Error        string `json:"error,omitempty"`
+}
+
+// RunSummary is the outcome of a whole run, printed at its end and written to the report file.
+type RunSummary struct {
+	StartTime time.Time        `json:"start_time"`
+	EndTime   time.Time        `json:"end_time"`
+	Duration  string           `json:"duration"`
+	DryRun    bool             `json:"dry_run"`
+	Listed    int64            `json:"listed"`
+	Filtered  int64            `json:"filtered"`
+	Copied    int64            `json:"copied"`
+	Skipped   int64            `json:"skipped"`
+	Failed    int64            `json:"failed"`
+	Deleted   int64            `json:"deleted"`
+	Bytes     int64            `json:"bytes"`
+	ExitCode  int              `json:"exit_code"`
+	Error     string           `json:"error,omitempty"`
+	Buckets   []*BucketSummary `json:"buckets"`
+}
+
+func newRunSummary() *RunSummary {
+	return &RunSummary{
+		StartTime: time.Now(),
+		DryRun:    DryRun,
+	}
+}
+
+func (summary *RunSummary) addBucket(job *bucketSyncJob, result *bucketSyncResult) {
+	bucket := &BucketSummary{
+		SourceBucket: job.sourceBucket,
+		TargetBucket: job.targetBucket,
+		Listed:       result.listed,
+		Filtered:     result.filtered,
+		Copied:       result.copied,
+		Skipped:      result.skipped,
+		Failed:       result.failed,
+		Deleted:      result.deleted,
+		Bytes:        result.bytes,
+	}
+	if result.err != nil {
+		bucket.Error = result.err.Error()
+	}
+	summary.Buckets = append(summary.Buckets, bucket)
+
+	summary.Listed += bucket.Listed
+	summary.Filtered += bucket.Filtered
+	summary.Copied += bucket.Copied
+	summary.Skipped += bucket.Skipped
+	summary.Failed += bucket.Failed
+	summary.Deleted += bucket.Deleted
+	summary.Bytes += bucket.Bytes
+}
+
+// bucketsError returns the error the whole run ends with. An interrupted run
+// ends as interrupted, when every bucket failed for the same reason that reason
+// is the error of the run, and any other failure makes the run a partial failure.
+func (summary *RunSummary) bucketsError(results []*bucketSyncResult) error {
+	var failedBuckets int
+	exitCode := ExitSuccess
+	wasInterrupted := false
+	for _, result := range results {
+		if result.err == nil {
+			continue
+		}
+		if result.err == errInterrupted {
+			wasInterrupted = true
+		}
+		if failedBuckets == 0 {
+			exitCode = ExitCode(result.err)
+		} else if exitCode != ExitCode(result.err) {
+			exitCode = ExitPartialFailure
+		}
+		failedBuckets++
+	}
+
+	if wasInterrupted {
+		return &SyncError{
+			ExitCode: ExitInterrupted,
+			Err:      fmt.Errorf("interrupted, %d buckets not fully synced, %d objects failed", failedBuckets, summary.Failed),
+		}
+	}
+	if failedBuckets > 0 && failedBuckets == len(results) {
+		return &SyncError{ExitCode: exitCode, Err: results[0].err}
+	}
+	if failedBuckets > 0 || summary.Failed > 0 {
+		return &SyncError{
+			ExitCode: ExitPartialFailure,
+			Err:      fmt.Errorf("%d buckets not fully synced, %d objects failed", failedBuckets, summary.Failed),
+		}
+	}
+	return nil
+}
+
+// finish completes the summary with the error the run ends with, prints it
+// and writes it to the report file when one is asked for.
+func (summary *RunSummary) finish(err error) {
+	summary.EndTime = time.Now()
+	summary.Duration = summary.EndTime.Sub(summary.StartTime).Round(time.Millisecond).String()
+	summary.ExitCode = ExitCode(err)
+	if err != nil {
+		summary.Error = err.Error()
+	}
+
+	summary.print()
+	if ReportFile == "" {
+		return
+	}
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(ReportFile, data, 0644)
+	}
+	if err != nil {
+		logrus.Errorf("write report file: %s failed, error: %v", ReportFile, err)
+	}
+}
+
+func (summary *RunSummary) print() {
+	fmt.Println("sync summary:")
+	for _, bucket := range summary.Buckets {
+		status := "ok"
+		if bucket.Error != "" {
+			status = "error: " + bucket.Error
+		} else if bucket.Failed > 0 {
+			status = "partial"
+		}
+		fmt.Printf("  bucket: %s -> %s, listed: %d, filtered: %d, copied: %d, skipped: %d, failed: %d, deleted: %d, bytes: %d, status: %s\n",
+			bucket.SourceBucket, bucket.TargetBucket, bucket.Listed, bucket.Filtered, bucket.Copied, bucket.Skipped,
+			bucket.Failed, bucket.Deleted, bucket.Bytes, status)
+	}
+	fmt.Printf("total buckets: %d, listed: %d, filtered: %d, copied: %d, skipped: %d, failed: %d, deleted: %d, bytes: %d\n",
+		len(summary.Buckets), summary.Listed, summary.Filtered, summary.Copied, summary.Skipped, summary.Failed, summary.Deleted, summary.Bytes)
+	fmt.Printf("duration: %s, exit code: %d\n", summary.Duration, summary.ExitCode)
+}
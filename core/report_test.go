@@ -0,0 +1,63 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBucketsError(t *testing.T) {
+	source := sourceError(errors.New("connection refused"))
+	target := targetError(errors.New("connection refused"))
+	config := configError(errors.New("--all-versions needs a source and a target with versioned buckets"))
+
+	tests := []struct {
+		name     string
+		errs     []error
+		failed   int64
+		exitCode int
+	}{
+		{name: "success", errs: []error{nil, nil}, exitCode: ExitSuccess},
+		{name: "no bucket", exitCode: ExitSuccess},
+		{name: "failed objects", errs: []error{nil, nil}, failed: 3, exitCode: ExitPartialFailure},
+		{name: "failed bucket", errs: []error{nil, source}, exitCode: ExitPartialFailure},
+		{name: "every bucket unreachable at the source", errs: []error{source, source}, exitCode: ExitSourceUnreachable},
+		{name: "every bucket unreachable at the target", errs: []error{target}, exitCode: ExitTargetUnreachable},
+		{name: "every bucket misconfigured", errs: []error{config, config}, exitCode: ExitConfigError},
+		{name: "buckets failed for different reasons", errs: []error{source, target}, exitCode: ExitPartialFailure},
+		{name: "interrupted", errs: []error{errInterrupted}, exitCode: ExitInterrupted},
+		{name: "interrupted after a synced bucket", errs: []error{nil, errInterrupted}, exitCode: ExitInterrupted},
+		{name: "interrupted with failures", errs: []error{source, errInterrupted}, failed: 2, exitCode: ExitInterrupted},
+	}
+
+	for _, test := range tests {
+		summary := &RunSummary{Failed: test.failed}
+		var results []*bucketSyncResult
+		for _, err := range test.errs {
+			results = append(results, &bucketSyncResult{err: err})
+		}
+		err := summary.bucketsError(results)
+		if exitCode := ExitCode(err); exitCode != test.exitCode {
+			t.Errorf("%s: exit code = %d, want %d, error: %v", test.name, exitCode, test.exitCode, err)
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err      error
+		exitCode int
+	}{
+		{err: nil, exitCode: ExitSuccess},
+		{err: errors.New("2 bucket configs failed"), exitCode: ExitPartialFailure},
+		{err: configError(errors.New("bad flag")), exitCode: ExitConfigError},
+		{err: sourceError(errors.New("no such host")), exitCode: ExitSourceUnreachable},
+		{err: targetError(errors.New("no such host")), exitCode: ExitTargetUnreachable},
+		{err: errInterrupted, exitCode: ExitInterrupted},
+	}
+
+	for _, test := range tests {
+		if exitCode := ExitCode(test.err); exitCode != test.exitCode {
+			t.Errorf("ExitCode(%v) = %d, want %d", test.err, exitCode, test.exitCode)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"github.com/shangjin92/ceph-sync/internal/store"
+	"github.com/sirupsen/logrus"
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultRetryBackoff    = time.Second
+	defaultRetryMaxBackoff = 30 * time.Second
+)
+
+// retryBackoff returns how long to wait before retry attempt, which starts at 1.
+// The backoff doubles with every attempt up to RetryMaxBackoff, and a random
+// jitter of up to half of it keeps workers from retrying in lockstep.
+func retryBackoff(attempt int) time.Duration {
+	backoff := RetryBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	maxBackoff := RetryMaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultRetryMaxBackoff
+	}
+
+	for i := 1; i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	jitter := time.Duration(rand.Int63n(int64(backoff)/2 + 1))
+	return backoff - jitter
+}
+
+// withRetry calls fn until it succeeds, fails with an error that is not
+// retryable, RetryAttempts retries have been made or the run is interrupted. It
+// returns the number of calls made and the last error.
+func withRetry(name string, fn func() error) (int, error) {
+	attempts := 0
+	for {
+		attempts++
+		err := fn()
+		if err == nil || !store.IsRetryable(err) || attempts > RetryAttempts {
+			return attempts, err
+		}
+
+		backoff := retryBackoff(attempts)
+		logrus.Warnf("%s failed, retry in %v, attempt: %d/%d, error: %v", name, backoff, attempts, RetryAttempts, err)
+		select {
+		case <-time.After(backoff):
+		case <-interrupted:
+			return attempts, err
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"errors"
+	"github.com/shangjin92/ceph-sync/internal/store"
+	"testing"
+	"time"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	RetryBackoff, RetryMaxBackoff = time.Second, 8*time.Second
+	defer func() {
+		RetryBackoff, RetryMaxBackoff = 0, 0
+	}()
+
+	// the backoff doubles up to the maximum, less a jitter of up to half of it
+	for i, base := range []time.Duration{1, 2, 4, 8, 8, 8, 8} {
+		attempt := i + 1
+		base *= time.Second
+		for i := 0; i < 100; i++ {
+			if backoff := retryBackoff(attempt); backoff < base/2 || backoff > base {
+				t.Fatalf("retryBackoff(%d) = %v, want between %v and %v", attempt, backoff, base/2, base)
+			}
+		}
+	}
+	if backoff := retryBackoff(1000); backoff > RetryMaxBackoff {
+		t.Errorf("retryBackoff(1000) = %v, want at most %v", backoff, RetryMaxBackoff)
+	}
+
+	RetryBackoff, RetryMaxBackoff = 0, 0
+	if backoff := retryBackoff(1); backoff < defaultRetryBackoff/2 || backoff > defaultRetryBackoff {
+		t.Errorf("default retryBackoff(1) = %v, want about %v", backoff, defaultRetryBackoff)
+	}
+	if backoff := retryBackoff(100); backoff < defaultRetryMaxBackoff/2 || backoff > defaultRetryMaxBackoff {
+		t.Errorf("default retryBackoff(100) = %v, want about %v", backoff, defaultRetryMaxBackoff)
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	RetryBackoff, RetryMaxBackoff = time.Microsecond, time.Microsecond
+	defer func() {
+		RetryBackoff, RetryMaxBackoff, RetryAttempts = 0, 0, 0
+	}()
+	transient := &store.HttpStatusError{StatusCode: 503, Status: "503 Service Unavailable"}
+	permanent := &store.HttpStatusError{StatusCode: 403, Status: "403 Forbidden"}
+
+	tests := []struct {
+		name         string
+		retries      int
+		errs         []error
+		wantAttempts int
+		wantErr      error
+	}{
+		{name: "success", retries: 3, wantAttempts: 1},
+		{name: "transient error then success", retries: 3, errs: []error{transient, transient}, wantAttempts: 3},
+		{name: "permanent error", retries: 3, errs: []error{permanent}, wantAttempts: 1, wantErr: permanent},
+		{name: "transient then permanent error", retries: 3, errs: []error{transient, permanent}, wantAttempts: 2, wantErr: permanent},
+		{name: "retries exhausted", retries: 2, errs: []error{transient, transient, transient, transient}, wantAttempts: 3, wantErr: transient},
+		{name: "no retries", retries: 0, errs: []error{transient}, wantAttempts: 1, wantErr: transient},
+	}
+
+	for _, test := range tests {
+		RetryAttempts = test.retries
+		calls := 0
+		attempts, err := withRetry(test.name, func() error {
+			calls++
+			if calls <= len(test.errs) {
+				return test.errs[calls-1]
+			}
+			return nil
+		})
+		if attempts != test.wantAttempts || calls != test.wantAttempts || !errors.Is(err, test.wantErr) {
+			t.Errorf("%s: withRetry made %d attempts in %d calls, error: %v, want %d attempts, error: %v",
+				test.name, attempts, calls, err, test.wantAttempts, test.wantErr)
+		}
+	}
+}
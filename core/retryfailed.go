@@ -0,0 +1,255 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"github.com/shangjin92/ceph-sync/internal/store"
+	"github.com/sirupsen/logrus"
+	"sync/atomic"
+	"time"
+)
+
+// RetryFailedObjects copies the objects of a failure manifest again, the
+// objects that still fail are written to a new failure manifest.
+func RetryFailedObjects() error {
+	logrus.Info("Begin retry failed objects...")
+	defer handleInterrupt()()
+
+	summary := newRunSummary()
+	err := retryFailedObjects(summary)
+	summary.finish(err)
+
+	logrus.Info("Finished retry failed objects...")
+	return err
+}
+
+func retryFailedObjects(summary *RunSummary) error {
+	if err := validateCompareMode(); err != nil {
+		logrus.Error(err)
+		return configError(err)
+	}
+
+	// the whole manifest is read before the new one is created, so both
+	// may be the same file
+	entries, err := readFailureManifest(RetryManifest)
+	if err != nil {
+		logrus.Errorf("read failure manifest: %s failed, error: %v", RetryManifest, err)
+		return configError(err)
+	}
+	if len(entries) == 0 {
+		logrus.Infof("failure manifest: %s is empty, nothing to retry.", RetryManifest)
+		return nil
+	}
+
+	sourceStoreClient, targetStoreClient, err := newStoreClients()
+	if err != nil {
+		return err
+	}
+	if err = checkAccessSupport(sourceStoreClient, targetStoreClient); err != nil {
+		logrus.Error(err)
+		return configError(err)
+	}
+	users, err := newUserMapper()
+	if err != nil {
+		logrus.Errorf("load acl user map failed, error: %v", err)
+		return configError(err)
+	}
+	storageClasses, err := newStorageClassMapper()
+	if err != nil {
+		logrus.Errorf("load storage class map failed, error: %v", err)
+		return configError(err)
+	}
+
+	versions, err := newRetryVersionMap(entries, sourceStoreClient, targetStoreClient)
+	if err != nil {
+		logrus.Error(err)
+		return configError(err)
+	}
+	defer versions.close()
+
+	failures, err := newFailureManifest()
+	if err != nil {
+		logrus.Error(err)
+		return configError(err)
+	}
+	defer failures.close()
+
+	var jobs []*bucketSyncJob
+	jobEntries := make(map[string][]*manifestEntry)
+	for _, entry := range entries {
+		key := fmt.Sprintf("%s -> %s", entry.SourceBucket, entry.TargetBucket)
+		if _, ok := jobEntries[key]; !ok {
+			jobs = append(jobs, &bucketSyncJob{
+				sourceBucket:   entry.SourceBucket,
+				targetBucket:   entry.TargetBucket,
+				dryRun:         DryRun,
+				failures:       failures,
+				users:          users,
+				storageClasses: storageClasses,
+				versions:       versions,
+				retry:          true,
+			})
+		}
+		jobEntries[key] = append(jobEntries[key], entry)
+	}
+
+	var plan *syncPlan
+	if DryRun {
+		plan = newSyncPlan(PlanOutput != "")
+	}
+
+	var results []*bucketSyncResult
+	for _, job := range jobs {
+		job.plan = plan
+		key := fmt.Sprintf("%s -> %s", job.sourceBucket, job.targetBucket)
+		result := retryBucketObjects(sourceStoreClient, targetStoreClient, job, jobEntries[key])
+		summary.addBucket(job, result)
+		results = append(results, result)
+	}
+
+	if plan != nil {
+		finishPlan(plan)
+	}
+	return summary.bucketsError(results)
+}
+
+// retryBucketObjects copies the manifest entries of one source and target
+// bucket through the same transfer pool as a bucket sync.
+func retryBucketObjects(sourceClient, targetClient store.Store, job *bucketSyncJob, entries []*manifestEntry) *bucketSyncResult {
+	result := &bucketSyncResult{}
+	if isInterrupted() {
+		keepManifestEntries(job, entries)
+		result.err = errInterrupted
+		return result
+	}
+	logrus.Infof("retry %d failed objects, bucket: %s -> %s", len(entries), job.sourceBucket, job.targetBucket)
+
+	if job.dryRun {
+		exist, _ := targetClient.CheckBucketExist(job.targetBucket)
+		job.targetBucketMissing = !exist
+	} else if _, err := createBucketIfAbsent(job.targetBucket, targetClient); err != nil {
+		result.err = targetError(err)
+		return result
+	}
+
+	pool := newTransferPool(sourceClient, targetClient, job, result)
+	for i, entry := range entries {
+		if pool.stopped() {
+			keepManifestEntries(job, entries[i:])
+			result.err = errInterrupted
+			break
+		}
+		if entry.TargetKey == "" {
+			// copying it again would fail the same way, it is kept in the manifest instead
+			logrus.Errorf("failed object has no target key, fix the key rewrite rules and sync it again, object name: %s, error: %s",
+				entry.Key, entry.Error)
+			atomic.AddInt64(&result.failed, 1)
+			if job.failures != nil {
+				job.failures.add(entry)
+			}
+			continue
+		}
+		if entry.VersionID != "" {
+			retryVersion(sourceClient, job, pool, entry)
+			continue
+		}
+
+		var object *store.ObjectInfo
+		attempts, err := withRetry("stat object "+entry.Key, func() (err error) {
+			object, err = sourceClient.StatObject(job.sourceBucket, entry.Key)
+			return err
+		})
+		if err == store.ErrObjectNotFound {
+			logrus.Infof("failed object no longer exists at the source, object name: %s", entry.Key)
+			atomic.AddInt64(&result.skipped, 1)
+			continue
+		}
+		if err != nil {
+			logrus.Errorf("stat failed object failed, object name: %s, error: %v", entry.Key, err)
+			atomic.AddInt64(&result.failed, 1)
+			if job.failures != nil {
+				retried := *entry
+				retried.Error = err.Error()
+				retried.Attempts += attempts
+				retried.Time = time.Now()
+				job.failures.add(&retried)
+			}
+			continue
+		}
+
+		task := &syncTask{
+			key:       entry.Key,
+			targetKey: entry.TargetKey,
+			object:    *object,
+			attempts:  entry.Attempts,
+		}
+		if !pool.submit(task) {
+			keepManifestEntries(job, entries[i:i+1])
+		}
+	}
+	pool.wait()
+	return result
+}
+
+// keepManifestEntries writes entries that are not retried because the run is
+// interrupted to the new failure manifest unchanged, which may replace the one
+// they were read from.
+func keepManifestEntries(job *bucketSyncJob, entries []*manifestEntry) {
+	if job.failures == nil {
+		return
+	}
+	for _, entry := range entries {
+		job.failures.add(entry)
+	}
+}
+
+// retryVersion submits the failed version of entry and the later versions of its
+// key to the pool.
+func retryVersion(sourceClient store.Store, job *bucketSyncJob, pool *transferPool, entry *manifestEntry) {
+	task, err := newRetryVersionTask(sourceClient.(store.VersionedStore), job, entry)
+	if err != nil {
+		logrus.Errorf("list failed object versions failed, object name: %s, error: %v", entry.Key, err)
+		atomic.AddInt64(&pool.result.failed, 1)
+		if job.failures != nil {
+			retried := *entry
+			retried.Error = err.Error()
+			retried.Time = time.Now()
+			job.failures.add(&retried)
+		}
+		return
+	}
+	if task == nil {
+		logrus.Infof("failed object version no longer exists at the source, object name: %s, version: %s", entry.Key, entry.VersionID)
+		atomic.AddInt64(&pool.result.skipped, 1)
+		return
+	}
+	if !pool.submit(task) {
+		keepManifestEntries(job, []*manifestEntry{entry})
+	}
+}
+
+// newRetryVersionMap opens --version-map when the failure manifest lists object
+// versions, so the replayed versions are recorded like in the failed run. It is
+// nil when there are no versions, no version map or nothing is copied because of
+// a dry run.
+func newRetryVersionMap(entries []*manifestEntry, sourceClient, targetClient store.Store) (*versionMap, error) {
+	hasVersions := false
+	for _, entry := range entries {
+		if entry.VersionID != "" {
+			hasVersions = true
+			break
+		}
+	}
+	if !hasVersions {
+		return nil, nil
+	}
+	_, sourceOk := sourceClient.(store.VersionedStore)
+	_, targetOk := targetClient.(store.VersionedStore)
+	if !sourceOk || !targetOk {
+		return nil, errors.New("the failure manifest lists object versions, retrying them needs a source and a target with versioned buckets, like ceph or s3")
+	}
+	if VersionMap == "" || DryRun {
+		return nil, nil
+	}
+	return openVersionMap(VersionMap)
+}
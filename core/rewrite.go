@@ -0,0 +1,175 @@
+package core
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// rewriteRule is a sed like "s#regexp#replacement#flags" key rewrite rule. The
+// replacement may refer to submatches as $1 or ${name}, the g flag replaces
+// every match instead of the first one and the i flag ignores case.
+type rewriteRule struct {
+	regexp      *regexp.Regexp
+	replacement string
+	global      bool
+}
+
+func parseRewriteRule(rule string) (*rewriteRule, error) {
+	if len(rule) < 2 || rule[0] != 's' {
+		return nil, fmt.Errorf("invalid rewrite rule: %q, expect s#regexp#replacement#", rule)
+	}
+	delimiter := rule[1]
+	parts := splitRewriteRule(rule[2:], delimiter)
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid rewrite rule: %q, expect s%cregexp%creplacement%c", rule, delimiter, delimiter, delimiter)
+	}
+
+	rewrite := &rewriteRule{replacement: parts[1]}
+	expr := parts[0]
+	for _, flag := range parts[2] {
+		switch flag {
+		case 'g':
+			rewrite.global = true
+		case 'i':
+			expr = "(?i)" + expr
+		default:
+			return nil, fmt.Errorf("invalid rewrite rule: %q, unknown flag: %c", rule, flag)
+		}
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rewrite rule: %q, error: %v", rule, err)
+	}
+	rewrite.regexp = re
+	return rewrite, nil
+}
+
+// splitRewriteRule splits a rule at its delimiters, a delimiter escaped with a
+// backslash is part of the regexp or replacement. Like in sed it matches itself
+// in the regexp, even when it is a regexp metacharacter like "|".
+func splitRewriteRule(rule string, delimiter byte) []string {
+	var parts []string
+	var part strings.Builder
+	for i := 0; i < len(rule); i++ {
+		switch {
+		case rule[i] == '\\' && i+1 < len(rule) && rule[i+1] == delimiter:
+			if len(parts) == 0 {
+				part.WriteString(regexp.QuoteMeta(string(delimiter)))
+			} else {
+				part.WriteByte(delimiter)
+			}
+			i++
+		case rule[i] == delimiter:
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteByte(rule[i])
+		}
+	}
+	return append(parts, part.String())
+}
+
+func (rewrite *rewriteRule) apply(key string) string {
+	if rewrite.global {
+		return rewrite.regexp.ReplaceAllString(key, rewrite.replacement)
+	}
+	match := rewrite.regexp.FindStringSubmatchIndex(key)
+	if match == nil {
+		return key
+	}
+	replaced := rewrite.regexp.ExpandString(nil, rewrite.replacement, key, match)
+	return key[:match[0]] + string(replaced) + key[match[1]:]
+}
+
+// keyTemplateData is what a key template is executed with, the parts of the key
+// after the rewrite rules have been applied.
+type keyTemplateData struct {
+	Bucket string
+	Key    string
+	Dir    string
+	Name   string
+	Base   string
+	Ext    string
+}
+
+var keyTemplateFuncs = template.FuncMap{
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"replace":    strings.ReplaceAll,
+	"trimPrefix": strings.TrimPrefix,
+	"trimSuffix": strings.TrimSuffix,
+}
+
+// keyMapper rewrites source object keys, with the rewrite rules in order and
+// then the key template.
+type keyMapper struct {
+	rules    []*rewriteRule
+	template *template.Template
+}
+
+// newKeyMapper compiles RewriteRules and KeyTemplate, it returns nil when there
+// is nothing to rewrite.
+func newKeyMapper() (*keyMapper, error) {
+	if len(RewriteRules) == 0 && KeyTemplate == "" {
+		return nil, nil
+	}
+
+	mapper := &keyMapper{}
+	for _, rule := range RewriteRules {
+		rewrite, err := parseRewriteRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		mapper.rules = append(mapper.rules, rewrite)
+	}
+	if KeyTemplate != "" {
+		tmpl, err := template.New("key").Funcs(keyTemplateFuncs).Option("missingkey=error").Parse(KeyTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key template: %q, error: %v", KeyTemplate, err)
+		}
+		mapper.template = tmpl
+		// fields that don't exist only fail on execution
+		if _, err = mapper.executeTemplate("bucket", "dir/name.ext"); err != nil {
+			return nil, fmt.Errorf("invalid key template: %q, error: %v", KeyTemplate, err)
+		}
+	}
+	return mapper, nil
+}
+
+// mapKey rewrites key, a nil mapper returns it unchanged.
+func (mapper *keyMapper) mapKey(bucket, key string) (string, error) {
+	if mapper == nil {
+		return key, nil
+	}
+	for _, rule := range mapper.rules {
+		key = rule.apply(key)
+	}
+	if mapper.template != nil {
+		return mapper.executeTemplate(bucket, key)
+	}
+	return key, nil
+}
+
+// executeTemplate builds the key of the template. Leading slashes are removed,
+// so "{{.Dir}}/{{.Name}}" maps a key without a directory to its name.
+func (mapper *keyMapper) executeTemplate(bucket, key string) (string, error) {
+	data := &keyTemplateData{
+		Bucket: bucket,
+		Key:    key,
+		Name:   path.Base(key),
+		Ext:    path.Ext(key),
+	}
+	if dir := path.Dir(key); dir != "." {
+		data.Dir = dir
+	}
+	data.Base = strings.TrimSuffix(data.Name, data.Ext)
+
+	var mapped strings.Builder
+	if err := mapper.template.Execute(&mapped, data); err != nil {
+		return "", err
+	}
+	return strings.TrimLeft(mapped.String(), "/"), nil
+}
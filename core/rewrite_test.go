@@ -0,0 +1,103 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitRewriteRule(t *testing.T) {
+	tests := []struct {
+		rule      string
+		delimiter byte
+		parts     []string
+	}{
+		{"a#b#", '#', []string{"a", "b", ""}},
+		{"a#b#g", '#', []string{"a", "b", "g"}},
+		{"a/b/", '/', []string{"a", "b", ""}},
+		{`a\/b/c/`, '/', []string{"a/b", "c", ""}},
+		{`a/b\/c/gi`, '/', []string{"a", "b/c", "gi"}},
+		{`a\.b#c#`, '#', []string{`a\.b`, "c", ""}},
+		{`a\|b|c\|d|`, '|', []string{`a\|b`, "c|d", ""}},
+		{"a#b", '#', []string{"a", "b"}},
+		{"a#b#c#d", '#', []string{"a", "b", "c", "d"}},
+		{"##", '#', []string{"", "", ""}},
+	}
+
+	for _, test := range tests {
+		if parts := splitRewriteRule(test.rule, test.delimiter); !reflect.DeepEqual(parts, test.parts) {
+			t.Errorf("splitRewriteRule(%q, %q) = %q, want %q", test.rule, test.delimiter, parts, test.parts)
+		}
+	}
+}
+
+func TestParseRewriteRule(t *testing.T) {
+	tests := []struct {
+		rule    string
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{rule: "s#^logs/#archive/#", key: "logs/a/logs/b", want: "archive/a/logs/b"},
+		{rule: "s#o#0#", key: "foo/boo", want: "f0o/boo"},
+		{rule: "s#o#0#g", key: "foo/boo", want: "f00/b00"},
+		{rule: "s#LOGS#archive#", key: "logs/a", want: "logs/a"},
+		{rule: "s#LOGS#archive#i", key: "logs/a", want: "archive/a"},
+		{rule: "s#O#0#gi", key: "foO", want: "f00"},
+		{rule: "s#^(\\d{4})-(\\d{2})/#$1/$2/#", key: "2021-08/a.log", want: "2021/08/a.log"},
+		{rule: "s#^(?P<year>\\d{4})-#${year}/#", key: "2021-a.log", want: "2021/a.log"},
+		{rule: `s/^a\/b/c\/d/`, key: "a/b/x", want: "c/d/x"},
+		{rule: `s|a\|b|c|`, key: "a|b", want: "c"},
+		{rule: "s#\\.tmp$##", key: "a.tmp", want: "a"},
+		{rule: "s#x#y#", key: "abc", want: "abc"},
+		{rule: "", wantErr: true},
+		{rule: "s", wantErr: true},
+		{rule: "x#a#b#", wantErr: true},
+		{rule: "s#a#b", wantErr: true},
+		{rule: "s#a#b#c#", wantErr: true},
+		{rule: "s#a#b#x", wantErr: true},
+		{rule: "s#(#b#", wantErr: true},
+		{rule: "s#[a-#b#", wantErr: true},
+	}
+
+	for _, test := range tests {
+		rewrite, err := parseRewriteRule(test.rule)
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseRewriteRule(%q) error = %v, want error: %v", test.rule, err, test.wantErr)
+			continue
+		}
+		if test.wantErr {
+			continue
+		}
+		if got := rewrite.apply(test.key); got != test.want {
+			t.Errorf("rule %q rewrites %q to %q, want %q", test.rule, test.key, got, test.want)
+		}
+	}
+}
+
+func TestKeyMapperTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		key      string
+		want     string
+	}{
+		{"{{.Dir}}/{{lower .Name}}", "logs/2021/App.LOG", "logs/2021/app.log"},
+		{"{{.Dir}}/{{lower .Name}}", "App.LOG", "app.log"},
+		{"{{.Dir}}/{{.Base}}{{upper .Ext}}", "a/b.txt", "a/b.TXT"},
+		{"{{.Bucket}}/{{.Key}}", "a/b.txt", "bucket/a/b.txt"},
+		{"{{.Dir}}/{{.Base}}/{{.Name}}", "b.txt", "b/b.txt"},
+		{"{{replace .Key \"/\" \"_\"}}", "a/b/c", "a_b_c"},
+	}
+
+	for _, test := range tests {
+		KeyTemplate = test.template
+		mapper, err := newKeyMapper()
+		if err != nil {
+			t.Errorf("newKeyMapper(%q) failed, error: %v", test.template, err)
+			continue
+		}
+		if got, err := mapper.mapKey("bucket", test.key); err != nil || got != test.want {
+			t.Errorf("template %q maps %q to %q, error: %v, want %q", test.template, test.key, got, err, test.want)
+		}
+	}
+	KeyTemplate = ""
+}
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"errors"
+	"github.com/magiconair/properties"
+	"strings"
+)
+
+// storageClassMapper picks the storage class of target objects, either the
+// --storage-class override or the storage class of the source object mapped
+// through the --storage-class-map table.
+type storageClassMapper struct {
+	override string
+	classes  map[string]string
+}
+
+// newStorageClassMapper loads StorageClassMap, a properties file of
+// "source-class = target-class" lines. It returns nil when storage classes are
+// neither preserved nor overridden.
+func newStorageClassMapper() (*storageClassMapper, error) {
+	if StorageClass == "" && !PreserveStorageClass {
+		if StorageClassMap != "" {
+			return nil, errors.New("--storage-class-map needs --preserve-storage-class")
+		}
+		return nil, nil
+	}
+
+	classes, err := loadStorageClassMap()
+	if err != nil {
+		return nil, err
+	}
+	return &storageClassMapper{override: StorageClass, classes: classes}, nil
+}
+
+// ossToS3StorageClasses are the S3 storage classes closest to the ones of OSS.
+var ossToS3StorageClasses = map[string]string{
+	"Standard":    "STANDARD",
+	"IA":          "STANDARD_IA",
+	"Archive":     "GLACIER",
+	"ColdArchive": "DEEP_ARCHIVE",
+}
+
+// s3ToOssStorageClasses are the OSS storage classes closest to the ones of S3.
+var s3ToOssStorageClasses = map[string]string{
+	"STANDARD":     "Standard",
+	"STANDARD_IA":  "IA",
+	"ONEZONE_IA":   "IA",
+	"GLACIER":      "Archive",
+	"DEEP_ARCHIVE": "ColdArchive",
+}
+
+// defaultStorageClassMap returns the storage classes translated between OSS and
+// S3 compatible stores, which name them differently, and nil between stores of
+// the same kind.
+func defaultStorageClassMap(sourceType, targetType string) map[string]string {
+	isOss := func(dataSourceType string) bool {
+		return strings.ToLower(dataSourceType) == "oss"
+	}
+	isS3 := func(dataSourceType string) bool {
+		switch strings.ToLower(dataSourceType) {
+		case "ceph", "s3":
+			return true
+		}
+		return false
+	}
+	switch {
+	case isOss(sourceType) && isS3(targetType):
+		return ossToS3StorageClasses
+	case isS3(sourceType) && isOss(targetType):
+		return s3ToOssStorageClasses
+	}
+	return nil
+}
+
+// loadStorageClassMap reads StorageClassMap on top of the default translation
+// between the source and target types, so the file only needs the classes that
+// translate differently, like the placement target storage classes of Ceph.
+func loadStorageClassMap() (map[string]string, error) {
+	classes := make(map[string]string)
+	for source, target := range defaultStorageClassMap(SourceType, TargetType) {
+		classes[source] = target
+	}
+	if StorageClassMap == "" {
+		return classes, nil
+	}
+	p, err := properties.LoadFile(StorageClassMap, properties.UTF8)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range p.Keys() {
+		classes[key] = p.MustGetString(key)
+	}
+	return classes, nil
+}
+
+// storageClass returns the storage class an object of the source storage class
+// is written to, empty for the default storage class of the target.
+func (mapper *storageClassMapper) storageClass(source string) string {
+	if mapper == nil {
+		return ""
+	}
+	if mapper.override != "" {
+		return mapper.override
+	}
+	if mapped, ok := mapper.classes[source]; ok {
+		return mapped
+	}
+	return source
+}
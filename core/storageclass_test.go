@@ -0,0 +1,38 @@
+package core
+
+import "testing"
+
+func TestStorageClassMapper(t *testing.T) {
+	tests := []struct {
+		sourceType string
+		targetType string
+		override   string
+		source     string
+		want       string
+	}{
+		{"oss", "ceph", "", "IA", "STANDARD_IA"},
+		{"oss", "s3", "", "Archive", "GLACIER"},
+		{"oss", "s3", "", "ColdArchive", "DEEP_ARCHIVE"},
+		{"oss", "s3", "", "Standard", "STANDARD"},
+		{"ceph", "oss", "", "STANDARD_IA", "IA"},
+		{"s3", "oss", "", "GLACIER", "Archive"},
+		{"oss", "oss", "", "IA", "IA"},
+		{"ceph", "s3", "", "STANDARD_IA", "STANDARD_IA"},
+		{"ceph", "ceph", "", "COLD", "COLD"},
+		{"oss", "ceph", "", "", ""},
+		{"oss", "ceph", "GLACIER", "IA", "GLACIER"},
+	}
+
+	for _, test := range tests {
+		SourceType, TargetType = test.sourceType, test.targetType
+		classes, err := loadStorageClassMap()
+		if err != nil {
+			t.Fatalf("load storage class map failed, error: %v", err)
+		}
+		mapper := &storageClassMapper{override: test.override, classes: classes}
+		if got := mapper.storageClass(test.source); got != test.want {
+			t.Errorf("%s to %s maps storage class %q to %q, want %q", test.sourceType, test.targetType, test.source, got, test.want)
+		}
+	}
+	SourceType, TargetType = "", ""
+}
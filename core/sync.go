@@ -2,12 +2,14 @@ package core
 
 import (
 	"errors"
+	"fmt"
 	"github.com/magiconair/properties"
 	"github.com/shangjin92/ceph-sync/internal/store"
 	"github.com/sirupsen/logrus"
-	"github.com/wonderivan/logger"
+	"path/filepath"
 	"strings"
-	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -17,6 +19,15 @@ const (
 	TargetClusterAccessKey = "target_cluster_access_key"
 	TargetClusterSecretKey = "target_cluster_secret_key"
 	TargetClusterEndpoint  = "target_cluster_endpoint"
+
+	SourceClusterSessionToken     = "source_cluster_session_token"
+	SourceClusterRegion           = "source_cluster_region"
+	SourceClusterAddressingStyle  = "source_cluster_addressing_style"
+	SourceClusterSignatureVersion = "source_cluster_signature_version"
+	TargetClusterSessionToken     = "target_cluster_session_token"
+	TargetClusterRegion           = "target_cluster_region"
+	TargetClusterAddressingStyle  = "target_cluster_addressing_style"
+	TargetClusterSignatureVersion = "target_cluster_signature_version"
 )
 
 type SourceDataSourceConfig struct {
@@ -25,45 +36,111 @@ type SourceDataSourceConfig struct {
 	clusterSecretKey string
 	clusterEndpoint  string
 	clusterBucket    string
+
+	clusterSessionToken     string
+	clusterRegion           string
+	clusterAddressingStyle  string
+	clusterSignatureVersion string
 }
 
 type TargetDataSourceConfig struct {
+	dataSourceType   string
 	clusterAccessKey string
 	clusterSecretKey string
 	clusterEndpoint  string
 	clusterBucket    string
+
+	clusterSessionToken     string
+	clusterRegion           string
+	clusterAddressingStyle  string
+	clusterSignatureVersion string
 }
 
-func loadSourceDataSourceConfig() *SourceDataSourceConfig {
-	p := properties.MustLoadFile(SyncProperties, properties.UTF8)
+func loadSyncProperties() (*properties.Properties, error) {
+	p, err := properties.LoadFile(SyncProperties, properties.UTF8)
+	if err != nil {
+		return nil, fmt.Errorf("load config: %s failed, error: %v", SyncProperties, err)
+	}
+	return p, nil
+}
+
+// requiredString returns the value of key, or an error when it is not configured.
+func requiredString(p *properties.Properties, key string) (string, error) {
+	value, ok := p.Get(key)
+	if !ok {
+		return "", fmt.Errorf("config: %s is missing key: %s", SyncProperties, key)
+	}
+	return value, nil
+}
+
+func loadSourceDataSourceConfig() (*SourceDataSourceConfig, error) {
+	p, err := loadSyncProperties()
+	if err != nil {
+		return nil, err
+	}
 
 	return &SourceDataSourceConfig{
 		dataSourceType:   SourceType,
 		clusterAccessKey: p.GetString(SourceClusterAccessKey, ""),
 		clusterSecretKey: p.GetString(SourceClusterSecretKey, ""),
 		clusterEndpoint:  p.GetString(SourceClusterEndpoint, ""),
-	}
+
+		clusterSessionToken:     p.GetString(SourceClusterSessionToken, ""),
+		clusterRegion:           p.GetString(SourceClusterRegion, ""),
+		clusterAddressingStyle:  p.GetString(SourceClusterAddressingStyle, ""),
+		clusterSignatureVersion: p.GetString(SourceClusterSignatureVersion, ""),
+	}, nil
 }
 
-func loadTargetDataSourceConfig() *TargetDataSourceConfig {
-	p := properties.MustLoadFile(SyncProperties, properties.UTF8)
+func loadTargetDataSourceConfig() (*TargetDataSourceConfig, error) {
+	p, err := loadSyncProperties()
+	if err != nil {
+		return nil, err
+	}
+
+	config := &TargetDataSourceConfig{
+		dataSourceType: TargetType,
 
-	return &TargetDataSourceConfig{
-		clusterSecretKey: p.MustGetString(TargetClusterSecretKey),
-		clusterAccessKey: p.MustGetString(TargetClusterAccessKey),
-		clusterEndpoint:  p.MustGetString(TargetClusterEndpoint),
+		clusterSessionToken:     p.GetString(TargetClusterSessionToken, ""),
+		clusterRegion:           p.GetString(TargetClusterRegion, ""),
+		clusterAddressingStyle:  p.GetString(TargetClusterAddressingStyle, ""),
+		clusterSignatureVersion: p.GetString(TargetClusterSignatureVersion, ""),
 	}
+	switch strings.ToLower(TargetType) {
+	case "local":
+		return config, nil
+	case "s3":
+		// AWS S3 needs no endpoint
+		config.clusterEndpoint = p.GetString(TargetClusterEndpoint, "")
+	default:
+		if config.clusterEndpoint, err = requiredString(p, TargetClusterEndpoint); err != nil {
+			return nil, err
+		}
+	}
+	if config.clusterSecretKey, err = requiredString(p, TargetClusterSecretKey); err != nil {
+		return nil, err
+	}
+	if config.clusterAccessKey, err = requiredString(p, TargetClusterAccessKey); err != nil {
+		return nil, err
+	}
+	return config, nil
 }
 
 func newSourceStoreClient(config *SourceDataSourceConfig) (store.Store, error) {
+	s3Config := &store.S3Config{
+		AccessKey:        config.clusterAccessKey,
+		SecretKey:        config.clusterSecretKey,
+		SessionToken:     config.clusterSessionToken,
+		EndPoint:         config.clusterEndpoint,
+		Region:           config.clusterRegion,
+		AddressingStyle:  config.clusterAddressingStyle,
+		SignatureVersion: config.clusterSignatureVersion,
+	}
 	switch strings.ToLower(config.dataSourceType) {
 	case "ceph":
-		cephConfig := &store.CephConfig{
-			AccessKey: config.clusterAccessKey,
-			SecretKey: config.clusterSecretKey,
-			EndPoint:  config.clusterEndpoint,
-		}
-		return store.NewCephClient(cephConfig)
+		return store.NewCephClient(s3Config)
+	case "s3":
+		return store.NewS3Client(s3Config)
 	case "oss":
 		ossConfig := &store.OssConfig{
 			AccessID:  config.clusterAccessKey,
@@ -72,105 +149,516 @@ func newSourceStoreClient(config *SourceDataSourceConfig) (store.Store, error) {
 		}
 		return store.NewOssClient(ossConfig)
 	case "local":
-		return store.NewLocalClient()
+		if SourceLocalDirName == "" {
+			return nil, errors.New("source-dir-path is required for local source")
+		}
+		rootDir, _ := localSourceDir()
+		return store.NewLocalClient(rootDir)
 	default:
 		return nil, errors.New("don't support this client")
 	}
 }
 
 func newTargetStoreClient(config *TargetDataSourceConfig) (store.Store, error) {
-	cephConfig := &store.CephConfig{
-		AccessKey: config.clusterAccessKey,
-		SecretKey: config.clusterSecretKey,
-		EndPoint:  config.clusterEndpoint,
+	s3Config := &store.S3Config{
+		AccessKey:        config.clusterAccessKey,
+		SecretKey:        config.clusterSecretKey,
+		SessionToken:     config.clusterSessionToken,
+		EndPoint:         config.clusterEndpoint,
+		Region:           config.clusterRegion,
+		AddressingStyle:  config.clusterAddressingStyle,
+		SignatureVersion: config.clusterSignatureVersion,
+		Multipart:        multipartConfig(),
+	}
+	switch strings.ToLower(config.dataSourceType) {
+	case "ceph":
+		return store.NewCephClient(s3Config)
+	case "s3":
+		return store.NewS3Client(s3Config)
+	case "oss":
+		ossConfig := &store.OssConfig{
+			AccessID:  config.clusterAccessKey,
+			AccessKey: config.clusterSecretKey,
+			EndPoint:  config.clusterEndpoint,
+			Multipart: multipartConfig(),
+		}
+		return store.NewOssClient(ossConfig)
+	case "local":
+		if TargetLocalDirName == "" {
+			return nil, errors.New("target-dir-path is required for local target")
+		}
+		return store.NewLocalClient(TargetLocalDirName)
+	default:
+		return nil, errors.New("don't support this client")
 	}
-
-	return store.NewCephClient(cephConfig)
 }
 
-func SyncClusterBucketData() {
-	logrus.Info("Begin sync data from source cluster bucket...")
-
-	sourceCephClusterConfig := loadSourceDataSourceConfig()
+// newStoreClients creates the source and target store clients, failures are
+// configuration errors since no request is sent yet.
+func newStoreClients() (store.Store, store.Store, error) {
+	sourceCephClusterConfig, err := loadSourceDataSourceConfig()
+	if err != nil {
+		logrus.Errorf("load source config failed, error: %v", err)
+		return nil, nil, configError(err)
+	}
 	sourceStoreClient, err := newSourceStoreClient(sourceCephClusterConfig)
 	if err != nil {
 		logrus.Errorf("create source store client failed, error: %v", err)
-		return
+		return nil, nil, configError(err)
 	}
 
-	targetCephClusterConfig := loadTargetDataSourceConfig()
+	targetCephClusterConfig, err := loadTargetDataSourceConfig()
+	if err != nil {
+		logrus.Errorf("load target config failed, error: %v", err)
+		return nil, nil, configError(err)
+	}
 	targetStoreClient, err := newTargetStoreClient(targetCephClusterConfig)
 	if err != nil {
 		logrus.Errorf("create target store client failed, error: %v", err)
-		return
+		return nil, nil, configError(err)
+	}
+	return sourceStoreClient, targetStoreClient, nil
+}
+
+func multipartConfig() store.MultipartConfig {
+	const mb = 1024 * 1024
+	return store.MultipartConfig{
+		PartSize:    MultipartPartSize * mb,
+		Concurrency: MultipartConcurrency,
+		Threshold:   MultipartThreshold * mb,
 	}
+}
 
-	syncBucketData(sourceStoreClient, targetStoreClient)
+// SyncClusterBucketData syncs the source bucket, or the local source directory,
+// to the target bucket under the target object prefix.
+func SyncClusterBucketData() error {
+	logrus.Info("Begin sync data from source cluster bucket...")
+	defer handleInterrupt()()
+
+	summary := newRunSummary()
+	err := syncClusterBucketData(summary)
+	summary.finish(err)
 
 	logrus.Info("Finished sync data from source cluster bucket...")
+	return err
+}
+
+func syncClusterBucketData(summary *RunSummary) error {
+	if err := validateCompareMode(); err != nil {
+		logrus.Error(err)
+		return configError(err)
+	}
+
+	filter, err := newObjectFilter()
+	if err != nil {
+		logrus.Errorf("load filters failed, error: %v", err)
+		return configError(err)
+	}
+
+	mapper, err := newKeyMapper()
+	if err != nil {
+		logrus.Errorf("load key rewrite rules failed, error: %v", err)
+		return configError(err)
+	}
+
+	sourceStoreClient, targetStoreClient, err := newStoreClients()
+	if err != nil {
+		return err
+	}
+	if err = checkAccessSupport(sourceStoreClient, targetStoreClient); err != nil {
+		logrus.Error(err)
+		return configError(err)
+	}
+	if err = checkVersionSupport(sourceStoreClient, targetStoreClient); err != nil {
+		logrus.Error(err)
+		return configError(err)
+	}
+	if err = checkMirrorSupport(); err != nil {
+		logrus.Error(err)
+		return configError(err)
+	}
+	users, err := newUserMapper()
+	if err != nil {
+		logrus.Errorf("load acl user map failed, error: %v", err)
+		return configError(err)
+	}
+	storageClasses, err := newStorageClassMapper()
+	if err != nil {
+		logrus.Errorf("load storage class map failed, error: %v", err)
+		return configError(err)
+	}
+
+	cp, err := newRunCheckpoint()
+	if err != nil {
+		logrus.Errorf("load checkpoint failed, error: %v", err)
+		return configError(err)
+	}
+
+	failures, err := newFailureManifest()
+	if err != nil {
+		logrus.Error(err)
+		return configError(err)
+	}
+	defer failures.close()
+
+	versions, err := newVersionMap()
+	if err != nil {
+		logrus.Error(err)
+		return configError(err)
+	}
+	defer versions.close()
+
+	var sourceBucket = SourceClusterBucket
+	var targetObjectPrefix = TargetClusterObjectPrefix
+	if strings.ToLower(SourceType) == "local" {
+		_, sourceBucket = localSourceDir()
+		if IncludeRootDir {
+			targetObjectPrefix += sourceBucket + "/"
+		}
+	}
+	job := &bucketSyncJob{
+		sourceBucket:       sourceBucket,
+		sourceObjectPrefix: SourceClusterObjectPrefix,
+		targetBucket:       TargetClusterBucket,
+		targetObjectPrefix: targetObjectPrefix,
+		mirror:             MirrorDelete || DeleteDryRun,
+		dryRun:             DryRun,
+		checkpoint:         cp,
+		failures:           failures,
+		filter:             filter,
+		stripSourcePrefix:  StripSourcePrefix,
+		keyMapper:          mapper,
+		users:              users,
+		storageClasses:     storageClasses,
+		allVersions:        AllVersions,
+		versions:           versions,
+	}
+	if DryRun {
+		job.plan = newSyncPlan(PlanOutput != "")
+	}
+	result := syncBucketData(sourceStoreClient, targetStoreClient, job)
+	if job.plan != nil {
+		finishPlan(job.plan)
+	}
+
+	summary.addBucket(job, result)
+	return summary.bucketsError([]*bucketSyncResult{result})
 }
 
-func createBucketIfAbsent(bucketName string, targetClient store.Store) error {
+// localSourceDir splits the local source directory into the root directory of
+// the local source client and the name of the directory, which is used as the
+// source bucket, so object keys are relative to the source directory.
+func localSourceDir() (string, string) {
+	dirName, err := filepath.Abs(SourceLocalDirName)
+	if err != nil {
+		dirName = filepath.Clean(SourceLocalDirName)
+	}
+	return filepath.Dir(dirName), filepath.Base(dirName)
+}
+
+// createBucketIfAbsent creates the target bucket unless it exists, and reports
+// whether it was created.
+func createBucketIfAbsent(bucketName string, targetClient store.Store) (bool, error) {
 	checkResult, _ := targetClient.CheckBucketExist(bucketName)
 	if checkResult {
-		return nil
+		return false, nil
 	}
 	err := targetClient.CreateBucket(bucketName)
 	if err != nil {
 		logrus.Errorf("create bucket failed, error: %v", err)
-		return err
+		return false, err
 	}
-	return nil
+	return true, nil
 }
 
-func syncBucketData(sourceClient, targetClient store.Store) {
-	err := createBucketIfAbsent(TargetClusterBucket, targetClient)
+// bucketSyncJob describes which source bucket is copied into which target bucket.
+type bucketSyncJob struct {
+	sourceBucket       string
+	sourceObjectPrefix string
+	targetBucket       string
+	targetObjectPrefix string
+
+	// mirror deletes target objects missing from the source
+	mirror bool
+
+	// dryRun only records into plan what the sync would do, without
+	// creating the target bucket or changing any object
+	dryRun              bool
+	plan                *syncPlan
+	targetBucketMissing bool
+
+	// checkpoint records the progress of the job in pages, startMarker and
+	// retryObjects are where a resumed job picks up
+	checkpoint   *checkpoint
+	progress     *bucketCheckpoint
+	pages        *pageTracker
+	startMarker  string
+	retryObjects []*failedObject
+
+	// failures records the objects that could not be copied
+	failures *failureManifest
+	// retry is set when the job copies the objects of a failure manifest, the
+	// objects an interrupt drops are written to the new manifest then
+	retry bool
+
+	// filter selects the listed objects to sync
+	filter *objectFilter
+
+	// stripSourcePrefix and keyMapper rewrite source keys to target keys
+	stripSourcePrefix bool
+	keyMapper         *keyMapper
+
+	// users maps the user IDs of copied ACLs and bucket policies
+	users *userMapper
+
+	// storageClasses picks the storage class of the target objects
+	storageClasses *storageClassMapper
+
+	// allVersions replays every version of the source objects, versions
+	// records the replayed ones
+	allVersions bool
+	versions    *versionMap
+}
+
+// targetObjectName maps a source object key to its key in the target bucket:
+// the source prefix is stripped when asked for, the key is rewritten and the
+// target prefix is prepended. The same mapping is used to copy, compare and
+// mirror objects.
+func (job *bucketSyncJob) targetObjectName(key string) (string, error) {
+	mapped := key
+	if job.stripSourcePrefix {
+		mapped = strings.TrimPrefix(mapped, job.sourceObjectPrefix)
+	}
+	mapped, err := job.keyMapper.mapKey(job.sourceBucket, mapped)
 	if err != nil {
-		logrus.Errorf("Create bucket failed, bucket name: %s", TargetClusterBucket)
-		return
+		return "", fmt.Errorf("rewrite key: %s failed, error: %v", key, err)
+	}
+	mapped = job.targetObjectPrefix + mapped
+	if mapped == "" {
+		return "", fmt.Errorf("key: %s is rewritten to an empty key", key)
 	}
+	return mapped, nil
+}
 
-	marker := ""
-	for {
-		logrus.Infof("sync data to target cluster, bucket name: %s", TargetClusterBucket)
+// addFailure records an object that failed before it could be submitted, in the
+// checkpoint to be retried on resume and in the failure manifest.
+func (job *bucketSyncJob) addFailure(key, targetKey string, err error) {
+	if job.progress != nil {
+		job.checkpoint.addFailed(job.progress, key, err)
+	}
+	if job.failures != nil {
+		job.failures.add(&manifestEntry{
+			SourceBucket: job.sourceBucket,
+			Key:          key,
+			TargetBucket: job.targetBucket,
+			TargetKey:    targetKey,
+			Error:        err.Error(),
+			Attempts:     1,
+			Time:         time.Now(),
+		})
+	}
+}
+
+// bucketSyncResult counts what happened to the objects of a single bucketSyncJob.
+type bucketSyncResult struct {
+	listed   int64
+	filtered int64
+	copied   int64
+	skipped  int64
+	failed   int64
+	deleted  int64
+	bytes    int64
+	err      error
+}
 
-		var sourceBucket = SourceClusterBucket
-		if SourceClusterBucket == "" && SourceLocalDirName != "" {
-			sourceBucket = SourceLocalDirName
+func syncBucketData(sourceClient, targetClient store.Store, job *bucketSyncJob) *bucketSyncResult {
+	result := &bucketSyncResult{}
+
+	if job.checkpoint != nil {
+		progress, err := job.checkpoint.bucket(job)
+		if err != nil {
+			logrus.Error(err)
+			result.err = configError(err)
+			return result
+		}
+		job.progress = progress
+		if job.progress.Finished {
+			logrus.Infof("bucket: %s has been synced by a previous run, skip it", job.sourceBucket)
+			return result
 		}
-		listObjectResult, err2 := sourceClient.ListObjects(sourceBucket, marker, SourceClusterObjectPrefix)
-		if err2 != nil {
-			logrus.Errorf("list objects failed, source type: %s, source cluster bucket: %s", SourceType, SourceClusterBucket)
-			return
+		job.startMarker = job.progress.Marker
+		job.retryObjects = job.checkpoint.takeFailed(job.progress)
+		job.pages = newPageTracker(job.checkpoint, job.progress)
+		if job.startMarker != "" || len(job.retryObjects) > 0 {
+			logrus.Infof("resume bucket: %s after object: %q, retry %d failed objects",
+				job.sourceBucket, job.startMarker, len(job.retryObjects))
 		}
+	}
 
-		var wg sync.WaitGroup
-		for _, key := range listObjectResult.ObjectsName {
-			wg.Add(1)
-			objectUrl, urlType, err3 := sourceClient.GetObjectUrl(sourceBucket, key)
-			if err3 != nil {
-				logrus.Errorf("get object url failed, object name: %s, error: %v", key, err3)
-				return
+	if job.dryRun {
+		exist, _ := targetClient.CheckBucketExist(job.targetBucket)
+		job.targetBucketMissing = !exist
+		if exist && job.allVersions {
+			if err := prepareVersionedBucket(targetClient, job, false); err != nil {
+				logrus.Warnf("the sync would fail, error: %v", err)
 			}
-			var objectName = key
-			if TargetClusterObjectPrefix != "" {
-				objectName = TargetClusterObjectPrefix + objectName
+		}
+	} else {
+		created, err := createBucketIfAbsent(job.targetBucket, targetClient)
+		if err != nil {
+			logrus.Errorf("Create bucket failed, bucket name: %s", job.targetBucket)
+			result.err = targetError(err)
+			return result
+		}
+		if job.allVersions {
+			if err = prepareVersionedBucket(targetClient, job, created); err != nil {
+				result.err = targetError(err)
+				return result
 			}
-			go func(urlStr, dstObjectName string) {
-				defer wg.Done()
-				err = targetClient.UploadFile(urlType, urlStr, TargetClusterBucket, dstObjectName)
-				if err != nil {
-					logger.Error("upload object failed, bucket: %s, name: %s, error: %v", TargetClusterBucket, dstObjectName, err)
-				}
-			}(objectUrl, objectName)
 		}
-		wg.Wait()
+		if err = syncBucketAccess(sourceClient, targetClient, job); err != nil {
+			result.err = err
+			return result
+		}
+	}
+
+	logrus.Infof("sync data to target cluster, bucket name: %s, workers: %d", job.targetBucket, workerCount())
+	pool := newTransferPool(sourceClient, targetClient, job, result)
+	if job.allVersions {
+		result.err = listSourceVersions(sourceClient, job, pool)
+	} else {
+		result.err = listSourceObjects(sourceClient, job, pool)
+	}
+	pool.wait()
+
+	// a partial source listing would make every unlisted object look deleted
+	if result.err == nil && job.mirror && !job.targetBucketMissing {
+		result.err = deleteExtraneousObjects(sourceClient, targetClient, job, result)
+	}
+
+	if result.err == nil {
+		if job.checkpoint != nil {
+			job.checkpoint.finish(job.progress)
+		}
+		logrus.Info("sync process has finished.")
+	}
+	return result
+}
+
+// listSourceObjects feeds every object of the source bucket to the pool. The pool
+// queue holds about one listing page, so the next page is listed while the
+// objects of the current one are still being transferred.
+func listSourceObjects(sourceClient store.Store, job *bucketSyncJob, pool *transferPool) error {
+	if len(job.retryObjects) > 0 {
+		if err := submitRetryObjects(sourceClient, job, pool); err != nil {
+			return err
+		}
+	}
+
+	marker := job.startMarker
+	for {
+		if pool.stopped() {
+			return errInterrupted
+		}
+		listObjectResult, err := listObjects(sourceClient, job.sourceBucket, marker, job.sourceObjectPrefix)
+		if err != nil {
+			logrus.Errorf("list objects failed, source type: %s, source cluster bucket: %s", SourceType, job.sourceBucket)
+			return sourceError(err)
+		}
+
+		var tasks []*syncTask
+		for _, object := range listObjectResult.Objects {
+			if !job.filter.includes(object) {
+				pool.result.filtered++
+				continue
+			}
+			targetKey, err := job.targetObjectName(object.Key)
+			if err != nil {
+				logrus.Errorf("map object name failed, error: %v", err)
+				atomic.AddInt64(&pool.result.failed, 1)
+				job.addFailure(object.Key, "", err)
+				continue
+			}
+			tasks = append(tasks, &syncTask{
+				key:       object.Key,
+				targetKey: targetKey,
+				object:    object,
+			})
+		}
+
+		var page *listPage
+		if job.pages != nil {
+			lastKey := marker
+			if len(listObjectResult.Objects) > 0 {
+				lastKey = listObjectResult.Objects[len(listObjectResult.Objects)-1].Key
+			}
+			page = job.pages.newPage(lastKey, len(tasks))
+		}
+		for _, task := range tasks {
+			task.page = page
+			if !pool.submit(task) {
+				return errInterrupted
+			}
+		}
+		if page != nil {
+			job.pages.seal(page)
+		}
 
 		if *listObjectResult.Suspend {
-			logrus.Info("sync process has finished.")
-			return
-		} else {
-			marker = *listObjectResult.NextMarker
+			return nil
+		}
+		marker = *listObjectResult.NextMarker
+	}
+}
+
+// submitRetryObjects queues the objects that failed in the run being resumed, as
+// a page in front of the listing that does not move the checkpoint marker.
+func submitRetryObjects(sourceClient store.Store, job *bucketSyncJob, pool *transferPool) error {
+	page := job.pages.newPage(job.startMarker, len(job.retryObjects))
+	for _, failed := range job.retryObjects {
+		targetKey, err := job.targetObjectName(failed.Key)
+		if err != nil {
+			logrus.Errorf("map object name failed, error: %v", err)
+			job.addFailure(failed.Key, "", err)
+			job.pages.done(page, err)
+			continue
+		}
+
+		var object *store.ObjectInfo
+		_, err = withRetry("stat object "+failed.Key, func() (err error) {
+			object, err = sourceClient.StatObject(job.sourceBucket, failed.Key)
+			return err
+		})
+		if err != nil {
+			if err == store.ErrObjectNotFound {
+				logrus.Infof("failed object no longer exists at the source, object name: %s", failed.Key)
+			} else {
+				logrus.Errorf("stat failed object failed, object name: %s, error: %v", failed.Key, err)
+				job.addFailure(failed.Key, targetKey, err)
+			}
+			job.pages.done(page, err)
+			continue
+		}
+		task := &syncTask{
+			key:       failed.Key,
+			targetKey: targetKey,
+			object:    *object,
+			page:      page,
+		}
+		if !pool.submit(task) {
+			return errInterrupted
 		}
 	}
+	job.pages.seal(page)
+	return nil
+}
+
+// listObjects lists a page of objects, retrying transient failures.
+func listObjects(client store.Store, bucketName, marker, prefix string) (*store.ListObjectsResult, error) {
+	var listObjectResult *store.ListObjectsResult
+	_, err := withRetry("list objects of bucket "+bucketName, func() (err error) {
+		listObjectResult, err = client.ListObjects(bucketName, marker, prefix)
+		return err
+	})
+	return listObjectResult, err
 }
@@ -0,0 +1,269 @@
+package core
+
+import (
+	"github.com/shangjin92/ceph-sync/internal/store"
+	"github.com/sirupsen/logrus"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultWorkers = 16
+	// taskQueueSize is about one listing page of the s3/oss listers
+	taskQueueSize = 1000
+)
+
+// syncTask is a single object to be copied from the source to the target bucket.
+type syncTask struct {
+	key       string
+	targetKey string
+	object    store.ObjectInfo
+	page      *listPage
+	// attempts made by earlier runs, when the task comes from a failure manifest
+	attempts int
+	// versions are the versions of the object to replay from the oldest to the
+	// newest one, when syncing all versions
+	versions []store.ObjectVersion
+}
+
+// transferPool copies objects with a fixed number of workers fed by a channel.
+type transferPool struct {
+	sourceClient store.Store
+	targetClient store.Store
+	job          *bucketSyncJob
+	result       *bucketSyncResult
+	// copier is set when objects are copied server side
+	copier store.ServerSideCopier
+
+	tasks chan *syncTask
+	wg    sync.WaitGroup
+	// stop is closed when the run is interrupted
+	stop <-chan struct{}
+}
+
+func workerCount() int {
+	if Workers <= 0 {
+		return defaultWorkers
+	}
+	return Workers
+}
+
+func newTransferPool(sourceClient, targetClient store.Store, job *bucketSyncJob, result *bucketSyncResult) *transferPool {
+	pool := &transferPool{
+		sourceClient: sourceClient,
+		targetClient: targetClient,
+		job:          job,
+		result:       result,
+		tasks:        make(chan *syncTask, taskQueueSize),
+		stop:         interrupted,
+	}
+	if copier, ok := targetClient.(store.ServerSideCopier); ok && ServerSideCopy && copier.CanCopyFrom(sourceClient) {
+		logrus.Infof("source and target are the same store, copy objects server side, bucket: %s", job.targetBucket)
+		pool.copier = copier
+	}
+
+	for i := 0; i < workerCount(); i++ {
+		pool.wg.Add(1)
+		go pool.work()
+	}
+	return pool
+}
+
+// submit queues a task, blocking while the queue is full, and reports whether
+// it was queued, which it isn't once the run is interrupted. Every version of a
+// task counts as an object.
+func (pool *transferPool) submit(task *syncTask) bool {
+	if pool.stopped() {
+		return false
+	}
+	select {
+	case pool.tasks <- task:
+	case <-pool.stop:
+		return false
+	}
+	if task.versions != nil {
+		atomic.AddInt64(&pool.result.listed, int64(len(task.versions)))
+	} else {
+		atomic.AddInt64(&pool.result.listed, 1)
+	}
+	return true
+}
+
+// stopped reports whether the run is interrupted.
+func (pool *transferPool) stopped() bool {
+	select {
+	case <-pool.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// wait stops accepting tasks and blocks until every queued task is done, or
+// dropped when the run is interrupted.
+func (pool *transferPool) wait() {
+	close(pool.tasks)
+	pool.wg.Wait()
+}
+
+func (pool *transferPool) work() {
+	defer pool.wg.Done()
+	for task := range pool.tasks {
+		// the pages of dropped tasks are never done, so the checkpoint
+		// marker stays in front of them
+		if pool.stopped() {
+			pool.drop(task)
+			continue
+		}
+		err := pool.handle(task)
+		if task.page != nil {
+			if err != nil {
+				pool.job.checkpoint.addFailed(pool.job.progress, task.key, err)
+			}
+			pool.job.pages.done(task.page, err)
+		}
+	}
+}
+
+// drop records a task the workers don't handle because the run is interrupted.
+// Only retried objects are written to the failure manifest, a sync lists the
+// dropped objects again.
+func (pool *transferPool) drop(task *syncTask) {
+	job := pool.job
+	if !job.retry || job.failures == nil {
+		return
+	}
+	entry := &manifestEntry{
+		SourceBucket: job.sourceBucket,
+		Key:          task.key,
+		TargetBucket: job.targetBucket,
+		TargetKey:    task.targetKey,
+		Error:        errInterrupted.Error(),
+		Attempts:     task.attempts,
+		Time:         time.Now(),
+	}
+	// the later versions of the key are replayed after the first one
+	if task.versions != nil {
+		entry.VersionID = task.versions[0].VersionID
+	}
+	job.failures.add(entry)
+}
+
+// handle copies the object of task unless it is in sync or this is a dry run,
+// the returned error is only set when the object could not be copied.
+func (pool *transferPool) handle(task *syncTask) error {
+	if task.versions != nil {
+		return pool.handleVersions(task)
+	}
+	action, err := planObject(pool.sourceClient, pool.targetClient, pool.job, task)
+	if err != nil {
+		logrus.Warnf("compare object failed, copy it anyway, object name: %s, error: %v", task.key, err)
+	}
+	if pool.job.plan != nil {
+		pool.job.plan.add(&planEntry{
+			Action:       action,
+			SourceBucket: pool.job.sourceBucket,
+			SourceKey:    task.key,
+			TargetBucket: pool.job.targetBucket,
+			TargetKey:    task.targetKey,
+			Size:         task.object.Size,
+		})
+	}
+	if action == actionSkip {
+		logrus.Debugf("object is in sync, skip it, object name: %s", task.key)
+		atomic.AddInt64(&pool.result.skipped, 1)
+		return nil
+	}
+	if pool.job.dryRun {
+		return nil
+	}
+
+	attempts, err := pool.copyObject(task)
+	if err != nil {
+		atomic.AddInt64(&pool.result.failed, 1)
+		if pool.job.failures != nil {
+			pool.job.failures.add(&manifestEntry{
+				SourceBucket: pool.job.sourceBucket,
+				Key:          task.key,
+				TargetBucket: pool.job.targetBucket,
+				TargetKey:    task.targetKey,
+				Error:        err.Error(),
+				Attempts:     task.attempts + attempts,
+				Time:         time.Now(),
+			})
+		}
+		return err
+	}
+	atomic.AddInt64(&pool.result.copied, 1)
+	atomic.AddInt64(&pool.result.bytes, task.object.Size)
+	return nil
+}
+
+// copyObject copies the object of task, retrying transient failures, and returns
+// the number of attempts made. Every attempt signs a new object url, so a retry
+// never reads through an expired one.
+func (pool *transferPool) copyObject(task *syncTask) (int, error) {
+	attempts, err := withRetry("copy object "+task.key, func() error {
+		opts, err := pool.uploadOptions(task)
+		if err != nil {
+			logrus.Errorf("read source object attributes failed, object name: %s, error: %v", task.key, err)
+			return err
+		}
+		if pool.copier != nil {
+			err = pool.copier.CopyObject(pool.job.sourceBucket, task.key, pool.job.targetBucket, task.targetKey, task.object.Size, opts)
+		} else {
+			err = pool.uploadObject(task, opts)
+		}
+		if err == nil && PreserveACL {
+			err = copyObjectACL(pool.sourceClient, pool.targetClient, pool.job, task)
+		}
+		return err
+	})
+	if err != nil {
+		logrus.Errorf("copy object failed, give up after %d attempts, object name: %s, retryable: %t",
+			attempts, task.key, store.IsRetryable(err))
+	}
+	return attempts, err
+}
+
+// uploadObject streams the source object of task to the target.
+func (pool *transferPool) uploadObject(task *syncTask, opts *store.UploadOptions) error {
+	objectUrl, urlType, err := pool.sourceClient.GetObjectUrl(pool.job.sourceBucket, task.key)
+	if err != nil {
+		logrus.Errorf("get object url failed, object name: %s, error: %v", task.key, err)
+		return err
+	}
+
+	err = pool.targetClient.UploadFile(urlType, objectUrl, pool.job.targetBucket, task.targetKey, opts)
+	if err != nil {
+		logrus.Errorf("upload object failed, bucket: %s, name: %s, error: %v", pool.job.targetBucket, task.targetKey, err)
+		return err
+	}
+	return nil
+}
+
+// uploadOptions returns what the target object is written with. Listings carry
+// no metadata, so it is read from the source object with a HEAD request, and
+// tags need a request of their own.
+func (pool *transferPool) uploadOptions(task *syncTask) (*store.UploadOptions, error) {
+	opts := &store.UploadOptions{
+		StorageClass: pool.job.storageClasses.storageClass(task.object.StorageClass),
+	}
+	if PreserveMetadata {
+		object, err := pool.sourceClient.StatObject(pool.job.sourceBucket, task.key)
+		if err != nil {
+			return nil, err
+		}
+		opts.Meta = object.Meta
+	}
+	if PreserveTags {
+		tags, err := pool.sourceClient.(store.TaggingStore).GetObjectTagging(pool.job.sourceBucket, task.key)
+		if err != nil {
+			logrus.Errorf("get source object tagging failed, object name: %s, error: %v", task.key, err)
+			return nil, err
+		}
+		opts.Tags = tags
+	}
+	return opts, nil
+}
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"github.com/shangjin92/ceph-sync/internal/store"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTransferPool(t *testing.T) {
+	Workers = 4
+	defer func() { Workers = 0 }()
+
+	source := newFakeStore(1000)
+	target := newFakeStore(1000)
+	target.put("target")
+	var lock sync.Mutex
+	attempts := make(map[string]int)
+	target.uploadHook = func(bucketName, objectName string) error {
+		lock.Lock()
+		defer lock.Unlock()
+		attempts[objectName]++
+		if strings.HasSuffix(objectName, ".bad") {
+			return errors.New("access denied")
+		}
+		return nil
+	}
+
+	result := &bucketSyncResult{}
+	pool := newTransferPool(source, target, &bucketSyncJob{sourceBucket: "source", targetBucket: "target"}, result)
+	var wantBytes int64
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("object-%03d", i)
+		if i%10 == 0 {
+			key += ".bad"
+		} else {
+			wantBytes += int64(len(key))
+		}
+		if !pool.submit(&syncTask{key: key, targetKey: key, object: store.ObjectInfo{Key: key, Size: int64(len(key))}}) {
+			t.Fatalf("submit object: %s failed", key)
+		}
+	}
+	pool.wait()
+
+	if len(attempts) != 100 {
+		t.Errorf("%d objects handled, want 100", len(attempts))
+	}
+	for key, count := range attempts {
+		if count != 1 {
+			t.Errorf("object: %s handled %d times, want once", key, count)
+		}
+	}
+	if len(target.uploads) != 90 {
+		t.Errorf("%d objects uploaded, want 90", len(target.uploads))
+	}
+	if result.listed != 100 || result.copied != 90 || result.failed != 10 || result.bytes != wantBytes {
+		t.Errorf("result is listed %d, copied %d, failed %d, bytes %d, want listed 100, copied 90, failed 10, bytes %d",
+			result.listed, result.copied, result.failed, result.bytes, wantBytes)
+	}
+}
+
+func TestTransferPoolInterrupt(t *testing.T) {
+	Workers = 2
+	interrupted = make(chan struct{})
+	FailureManifest = filepath.Join(t.TempDir(), "failed.jsonl")
+	defer func() {
+		Workers, interrupted, FailureManifest = 0, make(chan struct{}), ""
+	}()
+
+	source := newFakeStore(1000)
+	target := newFakeStore(1000)
+	target.put("target")
+	started := make(chan string)
+	release := make(chan struct{})
+	target.uploadHook = func(bucketName, objectName string) error {
+		started <- objectName
+		<-release
+		return nil
+	}
+
+	failures, err := newFailureManifest()
+	if err != nil {
+		t.Fatalf("create failure manifest failed, error: %v", err)
+	}
+	result := &bucketSyncResult{}
+	job := &bucketSyncJob{sourceBucket: "source", targetBucket: "target", failures: failures, retry: true}
+	pool := newTransferPool(source, target, job, result)
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("object-%d", i)
+		pool.submit(&syncTask{key: key, targetKey: key, object: store.ObjectInfo{Key: key, Size: int64(len(key))}, attempts: 1})
+	}
+
+	// both workers copy an object when the run is interrupted
+	<-started
+	<-started
+	close(interrupted)
+	if pool.submit(&syncTask{key: "late", targetKey: "late"}) {
+		t.Error("submit after the interrupt succeeded, want it refused")
+	}
+	close(release)
+	pool.wait()
+	failures.close()
+
+	if len(target.uploads) != 2 || result.copied != 2 {
+		t.Errorf("%d objects uploaded, %d copied, want the 2 being copied", len(target.uploads), result.copied)
+	}
+	if result.listed != 10 || result.failed != 0 {
+		t.Errorf("result is listed %d, failed %d, want listed 10, failed 0", result.listed, result.failed)
+	}
+	// the dropped objects are kept for the next retry
+	entries, err := readFailureManifest(FailureManifest)
+	if err != nil {
+		t.Fatalf("read failure manifest failed, error: %v", err)
+	}
+	if len(entries) != 8 {
+		t.Fatalf("%d dropped objects in the failure manifest, want 8", len(entries))
+	}
+	for _, entry := range entries {
+		if target.uploads["target/"+entry.Key] != 0 || entry.Error != errInterrupted.Error() || entry.Attempts != 1 {
+			t.Errorf("dropped object is %+v, want an object not uploaded, failed by the interrupt after 1 attempt", entry)
+		}
+	}
+}
@@ -1,11 +1,70 @@
 package core
 
+import "time"
+
 var (
 	SyncProperties            string
 	SourceType                string
 	SourceLocalDirName        string
+	IncludeRootDir            bool
 	SourceClusterBucket       string
 	SourceClusterObjectPrefix string
+	TargetType                string
+	TargetLocalDirName        string
 	TargetClusterBucket       string
 	TargetClusterObjectPrefix string
+
+	// FilterRules are the --include, --exclude and --filter-from rules in
+	// command line order, as "+ pattern", "- pattern" and ". file"
+	FilterRules    []string
+	MinSize        string
+	MaxSize        string
+	ModifiedAfter  string
+	ModifiedBefore string
+
+	StripSourcePrefix bool
+	RewriteRules      []string
+	KeyTemplate       string
+
+	Workers     int
+	CompareMode string
+	DryRun      bool
+	PlanOutput  string
+	ReportFile  string
+
+	CheckpointFile string
+	Resume         bool
+
+	FailureManifest string
+	RetryManifest   string
+
+	RetryAttempts   int
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+
+	MirrorDelete bool
+	MaxDelete    int
+	DeleteDryRun bool
+
+	MultipartPartSize    int64
+	MultipartConcurrency int
+	MultipartThreshold   int64
+
+	ServerSideCopy   bool
+	PreserveMetadata bool
+	PreserveACL      bool
+	ACLUserMap       string
+	CopyBucketPolicy bool
+	CopyBucketCORS   bool
+
+	PreserveTags         bool
+	PreserveStorageClass bool
+	StorageClass         string
+	StorageClassMap      string
+
+	AllVersions bool
+	VersionMap  string
+
+	// BucketConfigs are the bucket configurations bucket-config syncs
+	BucketConfigs []string
 )
@@ -0,0 +1,500 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/shangjin92/ceph-sync/internal/store"
+	"github.com/sirupsen/logrus"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// versionMapEntry is one line of a version map, a source version and the target
+// version it was replayed to.
+type versionMapEntry struct {
+	SourceBucket    string    `json:"source_bucket"`
+	Key             string    `json:"key"`
+	SourceVersionID string    `json:"source_version_id"`
+	TargetBucket    string    `json:"target_bucket"`
+	TargetKey       string    `json:"target_key"`
+	TargetVersionID string    `json:"target_version_id"`
+	DeleteMarker    bool      `json:"delete_marker,omitempty"`
+	Time            time.Time `json:"time"`
+}
+
+// replayedVersion identifies a source version replayed to a target bucket, the
+// same version may be replayed to several target buckets.
+type replayedVersion struct {
+	sourceBucket string
+	key          string
+	versionID    string
+	targetBucket string
+}
+
+// versionMap records every replayed version in a jsonl file. The file is kept
+// across runs, versions it already lists are not replayed again, so a rerun or a
+// resumed run doesn't duplicate versions on the target.
+type versionMap struct {
+	lock     sync.Mutex
+	fileName string
+	file     *os.File
+	writer   *bufio.Writer
+	replayed map[replayedVersion]struct{}
+}
+
+// newVersionMap opens VersionMap and reads the versions replayed by earlier runs.
+// It is nil when not syncing all versions or nothing is copied because of a dry
+// run.
+func newVersionMap() (*versionMap, error) {
+	if !AllVersions || DryRun {
+		return nil, nil
+	}
+	if VersionMap == "" {
+		return nil, errors.New("--all-versions needs a --version-map file, or every run replays the versions again")
+	}
+	return openVersionMap(VersionMap)
+}
+
+// openVersionMap reads the versions an existing version map lists, and opens it
+// to append the versions replayed by this run.
+func openVersionMap(fileName string) (*versionMap, error) {
+	versions := &versionMap{
+		fileName: fileName,
+		replayed: make(map[replayedVersion]struct{}),
+	}
+	if err := versions.read(); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(fileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open version map: %s failed, error: %v", fileName, err)
+	}
+	versions.file = file
+	versions.writer = bufio.NewWriter(file)
+	return versions, nil
+}
+
+func (versions *versionMap) read() error {
+	file, err := os.Open(versions.fileName)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open version map: %s failed, error: %v", versions.fileName, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		entry := &versionMapEntry{}
+		if err = json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			// the last line is cut short when a run crashed while writing it
+			logrus.Warnf("skip invalid version map: %s line %d, error: %v", versions.fileName, line, err)
+			continue
+		}
+		versions.replayed[entry.replayedVersion()] = struct{}{}
+	}
+	if len(versions.replayed) > 0 {
+		logrus.Infof("version map: %s lists %d replayed versions", versions.fileName, len(versions.replayed))
+	}
+	return scanner.Err()
+}
+
+func (entry *versionMapEntry) replayedVersion() replayedVersion {
+	return replayedVersion{entry.SourceBucket, entry.Key, entry.SourceVersionID, entry.TargetBucket}
+}
+
+// isReplayed reports whether a source version has been replayed to the target
+// bucket by this or an earlier run, a nil map has no replayed versions.
+func (versions *versionMap) isReplayed(sourceBucket, key, versionID, targetBucket string) bool {
+	if versions == nil {
+		return false
+	}
+	versions.lock.Lock()
+	defer versions.lock.Unlock()
+	_, ok := versions.replayed[replayedVersion{sourceBucket, key, versionID, targetBucket}]
+	return ok
+}
+
+// add records a replayed version, a nil map records nothing.
+func (versions *versionMap) add(entry *versionMapEntry) {
+	if versions == nil {
+		return
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		logrus.Errorf("marshal version map entry failed, error: %v", err)
+		return
+	}
+
+	versions.lock.Lock()
+	defer versions.lock.Unlock()
+	versions.replayed[entry.replayedVersion()] = struct{}{}
+	if _, err = versions.writer.Write(append(data, '\n')); err == nil {
+		// flush every entry, so a crashed run doesn't replay its versions again
+		err = versions.writer.Flush()
+	}
+	if err != nil {
+		logrus.Errorf("write version map: %s failed, error: %v", versions.fileName, err)
+	}
+}
+
+func (versions *versionMap) close() {
+	if versions == nil {
+		return
+	}
+
+	versions.lock.Lock()
+	defer versions.lock.Unlock()
+	if err := versions.file.Close(); err != nil {
+		logrus.Errorf("close version map: %s failed, error: %v", versions.fileName, err)
+	}
+}
+
+// checkVersionSupport returns an error when --all-versions is asked for but the
+// source or the target has no versioned buckets, or it is combined with options
+// that only work on current versions.
+func checkVersionSupport(sourceClient, targetClient store.Store) error {
+	if !AllVersions {
+		return nil
+	}
+	_, sourceOk := sourceClient.(store.VersionedStore)
+	_, targetOk := targetClient.(store.VersionedStore)
+	if !sourceOk || !targetOk {
+		return errors.New("--all-versions needs a source and a target with versioned buckets, like ceph or s3")
+	}
+	if CompareMode != CompareNone {
+		return errors.New("--all-versions can't be used with --compare, versions listed in the version map are skipped instead")
+	}
+	if MirrorDelete || DeleteDryRun {
+		return errors.New("--all-versions can't be used with --delete, delete markers are replayed instead")
+	}
+	if PreserveACL || PreserveTags {
+		return errors.New("--all-versions can't be used with --preserve-acl or --preserve-tags")
+	}
+	return nil
+}
+
+// prepareVersionedBucket enables versioning of a target bucket the sync created,
+// an existing target bucket must be versioned already.
+func prepareVersionedBucket(targetClient store.Store, job *bucketSyncJob, created bool) error {
+	versionedClient := targetClient.(store.VersionedStore)
+	if created {
+		return versionedClient.EnableBucketVersioning(job.targetBucket)
+	}
+	status, err := versionedClient.GetBucketVersioning(job.targetBucket)
+	if err != nil {
+		logrus.Errorf("get target bucket versioning failed, bucket: %s, error: %v", job.targetBucket, err)
+		return err
+	}
+	if status != store.VersioningEnabled {
+		return fmt.Errorf("versioning of target bucket: %s is not enabled", job.targetBucket)
+	}
+	return nil
+}
+
+// listSourceVersions feeds every key of the source bucket to the pool, as a task
+// with the versions of the key. A key whose versions continue on the next page
+// is submitted with that page.
+func listSourceVersions(sourceClient store.Store, job *bucketSyncJob, pool *transferPool) error {
+	versionedClient := sourceClient.(store.VersionedStore)
+	if len(job.retryObjects) > 0 {
+		if err := submitRetryVersions(versionedClient, job, pool); err != nil {
+			return err
+		}
+	}
+
+	keyMarker, versionIDMarker := job.startMarker, ""
+	var pending []store.ObjectVersion
+	for {
+		if pool.stopped() {
+			return errInterrupted
+		}
+		listResult, err := listObjectVersions(versionedClient, job.sourceBucket, keyMarker, versionIDMarker, job.sourceObjectPrefix)
+		if err != nil {
+			logrus.Errorf("list object versions failed, source type: %s, source cluster bucket: %s", SourceType, job.sourceBucket)
+			return sourceError(err)
+		}
+
+		var tasks []*syncTask
+		lastKey := keyMarker
+		for _, version := range listResult.Versions {
+			if len(pending) > 0 && pending[0].Key != version.Key {
+				if task := newVersionTask(job, pool, pending); task != nil {
+					tasks = append(tasks, task)
+				}
+				lastKey = pending[0].Key
+				pending = nil
+			}
+			pending = append(pending, version)
+		}
+		suspend := *listResult.Suspend
+		if !suspend && listResult.NextKeyMarker == nil {
+			logrus.Error("Unable to list all bucket object versions.")
+			suspend = true
+		}
+		if suspend && len(pending) > 0 {
+			if task := newVersionTask(job, pool, pending); task != nil {
+				tasks = append(tasks, task)
+			}
+			lastKey = pending[0].Key
+			pending = nil
+		}
+
+		var page *listPage
+		if job.pages != nil {
+			page = job.pages.newPage(lastKey, len(tasks))
+		}
+		for _, task := range tasks {
+			task.page = page
+			if !pool.submit(task) {
+				return errInterrupted
+			}
+		}
+		if page != nil {
+			job.pages.seal(page)
+		}
+
+		if suspend {
+			return nil
+		}
+		keyMarker = *listResult.NextKeyMarker
+		versionIDMarker = ""
+		if listResult.NextVersionIDMarker != nil {
+			versionIDMarker = *listResult.NextVersionIDMarker
+		}
+	}
+}
+
+// newVersionTask returns the task replaying the versions of a key, listed from
+// the newest to the oldest one, or nil when the filter excludes every version or
+// the key can't be mapped.
+func newVersionTask(job *bucketSyncJob, pool *transferPool, versions []store.ObjectVersion) *syncTask {
+	key := versions[0].Key
+	task := &syncTask{key: key, object: versions[0].ObjectInfo}
+	for i := len(versions) - 1; i >= 0; i-- {
+		if !job.filter.includesVersion(versions[i]) {
+			pool.result.filtered++
+			continue
+		}
+		task.versions = append(task.versions, versions[i])
+	}
+	if len(task.versions) == 0 {
+		return nil
+	}
+
+	targetKey, err := job.targetObjectName(key)
+	if err != nil {
+		logrus.Errorf("map object name failed, error: %v", err)
+		atomic.AddInt64(&pool.result.failed, int64(len(task.versions)))
+		job.addFailure(key, "", err)
+		return nil
+	}
+	task.targetKey = targetKey
+	return task
+}
+
+// submitRetryVersions queues the keys that failed in the run being resumed, as a
+// page in front of the listing that does not move the checkpoint marker. The
+// versions of a key already replayed are skipped by the version map.
+func submitRetryVersions(versionedClient store.VersionedStore, job *bucketSyncJob, pool *transferPool) error {
+	page := job.pages.newPage(job.startMarker, len(job.retryObjects))
+	for _, failed := range job.retryObjects {
+		versions, err := listKeyVersions(versionedClient, job.sourceBucket, failed.Key)
+		if err != nil {
+			logrus.Errorf("list failed object versions failed, object name: %s, error: %v", failed.Key, err)
+			// an empty target key would mark the key as not mappable
+			targetKey, _ := job.targetObjectName(failed.Key)
+			job.addFailure(failed.Key, targetKey, err)
+			job.pages.done(page, err)
+			continue
+		}
+		var task *syncTask
+		if len(versions) > 0 {
+			task = newVersionTask(job, pool, versions)
+		}
+		if task == nil {
+			logrus.Infof("failed object has no versions to replay, object name: %s", failed.Key)
+			job.pages.done(page, nil)
+			continue
+		}
+		task.page = page
+		if !pool.submit(task) {
+			return errInterrupted
+		}
+	}
+	job.pages.seal(page)
+	return nil
+}
+
+// newRetryVersionTask returns the task replaying the failed version of a failure
+// manifest entry and the later versions of its key, which the failed run did not
+// replay either. It is nil when the version no longer exists at the source.
+func newRetryVersionTask(versionedClient store.VersionedStore, job *bucketSyncJob, entry *manifestEntry) (*syncTask, error) {
+	versions, err := listKeyVersions(versionedClient, job.sourceBucket, entry.Key)
+	if err != nil {
+		return nil, err
+	}
+	for i, version := range versions {
+		if version.VersionID != entry.VersionID {
+			continue
+		}
+		task := &syncTask{key: entry.Key, targetKey: entry.TargetKey, object: versions[0].ObjectInfo, attempts: entry.Attempts}
+		// versions are listed from the newest to the oldest one
+		for j := i; j >= 0; j-- {
+			task.versions = append(task.versions, versions[j])
+		}
+		return task, nil
+	}
+	return nil, nil
+}
+
+// listKeyVersions lists every version of a single key.
+func listKeyVersions(versionedClient store.VersionedStore, bucketName, key string) ([]store.ObjectVersion, error) {
+	var versions []store.ObjectVersion
+	keyMarker, versionIDMarker := "", ""
+	for {
+		listResult, err := listObjectVersions(versionedClient, bucketName, keyMarker, versionIDMarker, key)
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range listResult.Versions {
+			// the prefix also lists longer keys, which sort after key
+			if version.Key != key {
+				return versions, nil
+			}
+			versions = append(versions, version)
+		}
+		if *listResult.Suspend || listResult.NextKeyMarker == nil {
+			return versions, nil
+		}
+		keyMarker = *listResult.NextKeyMarker
+		versionIDMarker = ""
+		if listResult.NextVersionIDMarker != nil {
+			versionIDMarker = *listResult.NextVersionIDMarker
+		}
+	}
+}
+
+// listObjectVersions lists a page of object versions, retrying transient failures.
+func listObjectVersions(client store.VersionedStore, bucketName, keyMarker, versionIDMarker, prefix string) (*store.ListObjectVersionsResult, error) {
+	var listResult *store.ListObjectVersionsResult
+	_, err := withRetry("list object versions of bucket "+bucketName, func() (err error) {
+		listResult, err = client.ListObjectVersions(bucketName, keyMarker, versionIDMarker, prefix)
+		return err
+	})
+	return listResult, err
+}
+
+// handleVersions replays the versions of task from the oldest to the newest one.
+// Versions after one that fails are not replayed, they would end up older than
+// it on the target.
+func (pool *transferPool) handleVersions(task *syncTask) error {
+	job := pool.job
+	for i, version := range task.versions {
+		if job.versions.isReplayed(job.sourceBucket, task.key, version.VersionID, job.targetBucket) {
+			logrus.Debugf("object version is replayed already, skip it, object name: %s, version: %s", task.key, version.VersionID)
+			atomic.AddInt64(&pool.result.skipped, 1)
+			continue
+		}
+		if job.plan != nil {
+			action := actionCreate
+			if version.DeleteMarker {
+				action = actionDelete
+			}
+			job.plan.add(&planEntry{
+				Action:          action,
+				SourceBucket:    job.sourceBucket,
+				SourceKey:       task.key,
+				SourceVersionID: version.VersionID,
+				TargetBucket:    job.targetBucket,
+				TargetKey:       task.targetKey,
+				Size:            version.Size,
+			})
+		}
+		if job.dryRun {
+			continue
+		}
+
+		targetVersionID, attempts, err := pool.copyVersion(task, version)
+		if err != nil {
+			atomic.AddInt64(&pool.result.failed, int64(len(task.versions)-i))
+			if job.failures != nil {
+				job.failures.add(&manifestEntry{
+					SourceBucket: job.sourceBucket,
+					Key:          task.key,
+					TargetBucket: job.targetBucket,
+					TargetKey:    task.targetKey,
+					VersionID:    version.VersionID,
+					Error:        err.Error(),
+					Attempts:     task.attempts + attempts,
+					Time:         time.Now(),
+				})
+			}
+			return err
+		}
+		job.versions.add(&versionMapEntry{
+			SourceBucket:    job.sourceBucket,
+			Key:             task.key,
+			SourceVersionID: version.VersionID,
+			TargetBucket:    job.targetBucket,
+			TargetKey:       task.targetKey,
+			TargetVersionID: targetVersionID,
+			DeleteMarker:    version.DeleteMarker,
+			Time:            time.Now(),
+		})
+		atomic.AddInt64(&pool.result.copied, 1)
+		atomic.AddInt64(&pool.result.bytes, version.Size)
+	}
+	return nil
+}
+
+// copyVersion writes a version to the target as its new current version,
+// retrying transient failures, and returns the target version ID and the number
+// of attempts made. Versions are always streamed, a server side copy of a
+// version is not supported.
+func (pool *transferPool) copyVersion(task *syncTask, version store.ObjectVersion) (string, int, error) {
+	sourceClient := pool.sourceClient.(store.VersionedStore)
+	targetClient := pool.targetClient.(store.VersionedStore)
+	var targetVersionID string
+	attempts, err := withRetry("copy object version "+task.key, func() (err error) {
+		if version.DeleteMarker {
+			targetVersionID, err = targetClient.PutDeleteMarker(pool.job.targetBucket, task.targetKey)
+			return err
+		}
+
+		opts := &store.UploadOptions{
+			StorageClass: pool.job.storageClasses.storageClass(version.StorageClass),
+		}
+		if PreserveMetadata {
+			object, err := sourceClient.StatObjectVersion(pool.job.sourceBucket, task.key, version.VersionID)
+			if err != nil {
+				logrus.Errorf("stat source object version failed, object name: %s, version: %s, error: %v", task.key, version.VersionID, err)
+				return err
+			}
+			opts.Meta = object.Meta
+		}
+		objectUrl, urlType, err := sourceClient.GetObjectVersionUrl(pool.job.sourceBucket, task.key, version.VersionID)
+		if err != nil {
+			logrus.Errorf("get object version url failed, object name: %s, version: %s, error: %v", task.key, version.VersionID, err)
+			return err
+		}
+		targetVersionID, err = targetClient.UploadFileVersion(urlType, objectUrl, pool.job.targetBucket, task.targetKey, opts)
+		return err
+	})
+	if err != nil {
+		logrus.Errorf("copy object version failed, give up after %d attempts, object name: %s, version: %s, retryable: %t",
+			attempts, task.key, version.VersionID, store.IsRetryable(err))
+	}
+	return targetVersionID, attempts, err
+}
@@ -0,0 +1,88 @@
+package core
+
+import (
+	"github.com/shangjin92/ceph-sync/internal/store"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewVersionTaskFilter(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2021, 8, d, 0, 0, 0, 0, time.UTC)
+	}
+	version := func(id string, size int64, modified time.Time) store.ObjectVersion {
+		return store.ObjectVersion{
+			ObjectInfo: store.ObjectInfo{Key: "a.txt", Size: size, LastModified: modified},
+			VersionID:  id,
+		}
+	}
+	marker := func(id string, modified time.Time) store.ObjectVersion {
+		return store.ObjectVersion{
+			ObjectInfo:   store.ObjectInfo{Key: "a.txt", LastModified: modified},
+			VersionID:    id,
+			DeleteMarker: true,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		filter   *objectFilter
+		rules    []string
+		versions []store.ObjectVersion
+		want     []string
+	}{
+		{
+			name:     "no filter",
+			versions: []store.ObjectVersion{marker("v3", day(3)), version("v2", 10, day(2)), version("v1", 200, day(1))},
+			want:     []string{"v1", "v2", "v3"},
+		},
+		{
+			name:     "min size keeps delete marker",
+			filter:   &objectFilter{minSize: 100, maxSize: -1},
+			versions: []store.ObjectVersion{marker("v3", day(3)), version("v2", 10, day(2)), version("v1", 200, day(1))},
+			want:     []string{"v1", "v3"},
+		},
+		{
+			name:     "modified before keeps later delete marker",
+			filter:   &objectFilter{minSize: -1, maxSize: -1, modifiedBefore: day(2)},
+			versions: []store.ObjectVersion{marker("v3", day(3)), version("v2", 10, day(2)), version("v1", 200, day(1))},
+			want:     []string{"v1", "v3"},
+		},
+		{
+			name:     "modified after keeps delete marker of older versions",
+			filter:   &objectFilter{minSize: -1, maxSize: -1, modifiedAfter: day(3)},
+			versions: []store.ObjectVersion{marker("v3", day(3)), version("v2", 10, day(2)), version("v1", 200, day(1))},
+			want:     []string{"v3"},
+		},
+		{
+			name:     "excluded key drops delete marker",
+			filter:   &objectFilter{minSize: -1, maxSize: -1},
+			rules:    []string{"- *.txt"},
+			versions: []store.ObjectVersion{marker("v2", day(2)), version("v1", 200, day(1))},
+		},
+	}
+
+	for _, test := range tests {
+		for _, rule := range test.rules {
+			if err := test.filter.addRule(rule, ""); err != nil {
+				t.Fatalf("add rule %q failed, error: %v", rule, err)
+			}
+		}
+		job := &bucketSyncJob{sourceBucket: "bucket", targetBucket: "bucket", filter: test.filter}
+		pool := &transferPool{job: job, result: &bucketSyncResult{}}
+
+		var got []string
+		if task := newVersionTask(job, pool, test.versions); task != nil {
+			for _, version := range task.versions {
+				got = append(got, version.VersionID)
+			}
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: replayed versions = %v, want %v", test.name, got, test.want)
+		}
+		if filtered := pool.result.filtered; filtered != int64(len(test.versions)-len(test.want)) {
+			t.Errorf("%s: filtered = %d, want %d", test.name, filtered, len(test.versions)-len(test.want))
+		}
+	}
+}
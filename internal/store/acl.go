@@ -0,0 +1,89 @@
+package store
+
+const (
+	CannedPrivate         = "private"
+	CannedPublicRead      = "public-read"
+	CannedPublicReadWrite = "public-read-write"
+
+	GranteeCanonicalUser = "CanonicalUser"
+	GranteeGroup         = "Group"
+	GranteeEmail         = "AmazonCustomerByEmail"
+
+	PermissionFullControl = "FULL_CONTROL"
+	PermissionRead        = "READ"
+	PermissionWrite       = "WRITE"
+
+	// AllUsersGroup is the grantee uri of anonymous access
+	AllUsersGroup = "http://acs.amazonaws.com/groups/global/AllUsers"
+)
+
+// ACL is the access control list of a bucket or an object, either a canned ACL
+// like public-read, as OSS has, or the owner and grants of an S3 ACL.
+type ACL struct {
+	Canned string
+	Owner  string
+	Grants []Grant
+}
+
+// Grant gives a permission to a user, by ID or email, or to a group.
+type Grant struct {
+	GranteeType string
+	ID          string
+	Email       string
+	URI         string
+	Permission  string
+}
+
+// cannedACL returns the canned ACL acl amounts to for anonymous access, the only
+// access a canned ACL of OSS tells about.
+func (acl *ACL) cannedACL() string {
+	switch acl.Canned {
+	case CannedPrivate, CannedPublicRead, CannedPublicReadWrite:
+		return acl.Canned
+	case "":
+	default:
+		return CannedPrivate
+	}
+
+	canned := CannedPrivate
+	for _, grant := range acl.Grants {
+		if grant.GranteeType != GranteeGroup || grant.URI != AllUsersGroup {
+			continue
+		}
+		switch grant.Permission {
+		case PermissionWrite, PermissionFullControl:
+			return CannedPublicReadWrite
+		case PermissionRead:
+			canned = CannedPublicRead
+		}
+	}
+	return canned
+}
+
+// CORSRule is a cross origin resource sharing rule of a bucket.
+type CORSRule struct {
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	ExposeHeaders  []string
+	MaxAgeSeconds  int
+}
+
+// ACLStore is implemented by stores with access control lists. A nil ACL means
+// the object has no ACL of its own and inherits the one of its bucket.
+type ACLStore interface {
+	GetBucketACL(bucketName string) (*ACL, error)
+	PutBucketACL(bucketName string, acl *ACL) error
+	GetObjectACL(bucketName, objectName string) (*ACL, error)
+	PutObjectACL(bucketName, objectName string, acl *ACL) error
+}
+
+// BucketPolicyStore is implemented by stores with bucket policies and CORS
+// rules. An empty policy or no rules mean the bucket has none, PutBucketCORS
+// removes the CORS rules of the bucket when given none.
+type BucketPolicyStore interface {
+	GetBucketPolicy(bucketName string) (string, error)
+	PutBucketPolicy(bucketName, policy string) error
+	GetBucketCORS(bucketName string) ([]CORSRule, error)
+	PutBucketCORS(bucketName string, rules []CORSRule) error
+}
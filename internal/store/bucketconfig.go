@@ -0,0 +1,67 @@
+package store
+
+import "time"
+
+// LifecycleRule is a lifecycle rule of a bucket, in the shape S3 and OSS share.
+// Days are 0 and dates zero when not set.
+type LifecycleRule struct {
+	ID      string
+	Prefix  string
+	Tags    map[string]string
+	Enabled bool
+
+	ExpirationDays            int
+	ExpirationDate            time.Time
+	ExpiredObjectDeleteMarker bool
+	Transitions               []LifecycleTransition
+
+	// noncurrent transitions count their days from when a version became
+	// noncurrent, and have no date
+	NoncurrentExpirationDays int
+	NoncurrentTransitions    []LifecycleTransition
+
+	AbortMultipartUploadDays int
+}
+
+// LifecycleTransition moves objects to another storage class.
+type LifecycleTransition struct {
+	Days         int
+	Date         time.Time
+	StorageClass string
+}
+
+// WebsiteConfig is the static website configuration of a bucket.
+type WebsiteConfig struct {
+	IndexDocument string
+	ErrorDocument string
+}
+
+// BucketEncryption is the default server side encryption of a bucket, with the
+// algorithm named like S3 does, AES256 or aws:kms.
+type BucketEncryption struct {
+	Algorithm string
+	KMSKeyID  string
+}
+
+const (
+	SSEAlgorithmAES256 = "AES256"
+	SSEAlgorithmKMS    = "aws:kms"
+)
+
+// BucketConfigStore is implemented by stores with bucket configurations. The Put
+// methods remove a configuration from the bucket when given no rules, no tags or
+// nil.
+type BucketConfigStore interface {
+	// GetBucketVersioning returns the versioning status of a bucket, empty
+	// when versioning has never been enabled.
+	GetBucketVersioning(bucketName string) (string, error)
+	PutBucketVersioning(bucketName, status string) error
+	GetBucketLifecycle(bucketName string) ([]LifecycleRule, error)
+	PutBucketLifecycle(bucketName string, rules []LifecycleRule) error
+	GetBucketTagging(bucketName string) (map[string]string, error)
+	PutBucketTagging(bucketName string, tags map[string]string) error
+	GetBucketWebsite(bucketName string) (*WebsiteConfig, error)
+	PutBucketWebsite(bucketName string, website *WebsiteConfig) error
+	GetBucketEncryption(bucketName string) (*BucketEncryption, error)
+	PutBucketEncryption(bucketName string, encryption *BucketEncryption) error
+}
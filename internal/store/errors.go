@@ -0,0 +1,97 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+// HttpStatusError is returned when reading an object url answers with a non 2xx status.
+type HttpStatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *HttpStatusError) Error() string {
+	return fmt.Sprintf("read object failed, http status: %s", e.Status)
+}
+
+// throttleCodes are the error codes s3 and oss answer with when a client sends too many requests.
+var throttleCodes = map[string]struct{}{
+	"SlowDown":             {},
+	"Throttling":           {},
+	"ThrottlingException":  {},
+	"RequestLimitExceeded": {},
+	"TooManyRequests":      {},
+	"RequestTimeout":       {},
+	"ServiceUnavailable":   {},
+	"InternalError":        {},
+}
+
+// IsRetryable reports whether err is transient, as server errors, throttling and
+// broken connections are, so the same request may succeed when it is sent again.
+// Permanent errors like access denied or a missing object are not retryable.
+func IsRetryable(err error) bool {
+	if err == nil || err == ErrObjectNotFound {
+		return false
+	}
+
+	var statusErr *HttpStatusError
+	if errors.As(err, &statusErr) {
+		return isRetryableStatus(statusErr.StatusCode)
+	}
+
+	switch e := err.(type) {
+	case awserr.RequestFailure:
+		if _, ok := throttleCodes[e.Code()]; ok {
+			return true
+		}
+		return isRetryableStatus(e.StatusCode())
+	case awserr.Error:
+		if _, ok := throttleCodes[e.Code()]; ok || request.IsErrorThrottle(e) {
+			return true
+		}
+		if e.OrigErr() != nil {
+			return IsRetryable(e.OrigErr())
+		}
+		return request.IsErrorRetryable(e)
+	case oss.ServiceError:
+		if _, ok := throttleCodes[e.Code]; ok {
+			return true
+		}
+		return isRetryableStatus(e.StatusCode)
+	case oss.UnexpectedStatusCodeError:
+		return isRetryableStatus(e.Got())
+	}
+
+	return isRetryableNetError(err)
+}
+
+func isRetryableStatus(statusCode int) bool {
+	return statusCode >= http.StatusInternalServerError ||
+		statusCode == http.StatusTooManyRequests ||
+		statusCode == http.StatusRequestTimeout
+}
+
+func isRetryableNetError(err error) bool {
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	// syscall errors like a missing local file are net errors too, the transient
+	// ones are checked above
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		_, isErrno := netErr.(syscall.Errno)
+		return !isErrno
+	}
+	return false
+}
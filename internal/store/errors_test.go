@@ -0,0 +1,58 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestIsRetryable(t *testing.T) {
+	s3Error := func(code string, statusCode int) error {
+		return awserr.NewRequestFailure(awserr.New(code, code, nil), statusCode, "request-id")
+	}
+
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{"nil", nil, false},
+		{"object not found", ErrObjectNotFound, false},
+		{"s3 access denied", s3Error("AccessDenied", 403), false},
+		{"s3 no such key", s3Error("NoSuchKey", 404), false},
+		{"s3 no such bucket", s3Error("NoSuchBucket", 404), false},
+		{"s3 internal error", s3Error("InternalError", 500), true},
+		{"s3 service unavailable", s3Error("ServiceUnavailable", 503), true},
+		{"s3 slow down", s3Error("SlowDown", 503), true},
+		{"s3 request timeout", s3Error("RequestTimeout", 400), true},
+		{"s3 too many requests", s3Error("TooManyRequests", 429), true},
+		{"s3 connection reset", awserr.New("RequestError", "send request failed", syscall.ECONNRESET), true},
+		{"s3 unexpected eof", awserr.New("SerializationError", "failed to read body", io.ErrUnexpectedEOF), true},
+		{"oss access denied", oss.ServiceError{Code: "AccessDenied", StatusCode: 403}, false},
+		{"oss no such key", oss.ServiceError{Code: "NoSuchKey", StatusCode: 404}, false},
+		{"oss internal error", oss.ServiceError{Code: "InternalError", StatusCode: 500}, true},
+		{"oss service unavailable", oss.ServiceError{Code: "ServiceUnavailable", StatusCode: 503}, true},
+		{"object url forbidden", &HttpStatusError{StatusCode: 403, Status: "403 Forbidden"}, false},
+		{"object url not found", &HttpStatusError{StatusCode: 404, Status: "404 Not Found"}, false},
+		{"object url server error", &HttpStatusError{StatusCode: 500, Status: "500 Internal Server Error"}, true},
+		{"object url unavailable", fmt.Errorf("copy failed: %w", &HttpStatusError{StatusCode: 503, Status: "503 Service Unavailable"}), true},
+		{"connection reset", &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, true},
+		{"wrapped connection reset", fmt.Errorf("upload failed: %w", syscall.ECONNRESET), true},
+		{"unexpected eof", io.ErrUnexpectedEOF, true},
+		{"wrapped unexpected eof", fmt.Errorf("read body: %w", io.ErrUnexpectedEOF), true},
+		{"local file missing", &os.PathError{Op: "open", Path: "/data/a.txt", Err: syscall.ENOENT}, false},
+		{"other error", errors.New("invalid key"), false},
+	}
+
+	for _, test := range tests {
+		if retryable := IsRetryable(test.err); retryable != test.retryable {
+			t.Errorf("%s: IsRetryable(%v) = %v, want %v", test.name, test.err, retryable, test.retryable)
+		}
+	}
+}
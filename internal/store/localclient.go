@@ -1,35 +1,155 @@
 package store
 
 import (
+	"errors"
+	"fmt"
 	"github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"mime"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 )
 
+// localTempSuffix marks the temporary files objects are written to before they
+// are renamed into place, listings skip them.
+const localTempSuffix = ".ceph-sync-tmp"
+
+// localListPageSize is the number of objects of a ListObjects page, the same as
+// the default of the s3 and oss listings.
+const localListPageSize = 1000
+
+// LocalClient stores objects as files. Every bucket is a directory under the root
+// directory, and object names are slash separated paths relative to the bucket.
 type LocalClient struct {
+	rootDir string
+	// pageSize is the number of objects of a ListObjects page
+	pageSize int
+
+	// openDirs keeps the sorted entries of the directories the last listing
+	// page stopped in, so the next page does not read a huge directory again
+	lock     sync.Mutex
+	openDirs map[string][]localDirEntry
+}
+
+func NewLocalClient(rootDir string) (*LocalClient, error) {
+	return &LocalClient{rootDir: rootDir, pageSize: localListPageSize}, nil
+}
+
+func (localClient *LocalClient) bucketPath(dirName string) string {
+	return filepath.Join(localClient.rootDir, dirName)
+}
+
+// objectPath maps an object name to its file under the bucket directory. Object
+// names are slash separated paths relative to the bucket: absolute names and
+// names with a ".." segment are refused since they could point outside of the
+// bucket directory.
+func (localClient *LocalClient) objectPath(dirName, objectName string) (string, error) {
+	if strings.HasPrefix(objectName, "/") || filepath.IsAbs(filepath.FromSlash(objectName)) {
+		return "", fmt.Errorf("object name: %q is an absolute path", objectName)
+	}
+	for _, segment := range strings.Split(objectName, "/") {
+		if segment == ".." {
+			return "", fmt.Errorf("object name: %q escapes the bucket directory", objectName)
+		}
+	}
+	if strings.Trim(objectName, "/") == "" {
+		return "", fmt.Errorf("object name: %q has no file name", objectName)
+	}
+	return filepath.Join(localClient.bucketPath(dirName), filepath.FromSlash(objectName)), nil
 }
 
-func NewLocalClient() (*LocalClient, error) {
-	return &LocalClient{}, nil
+// isDirObject reports whether objectName is a directory marker, like the
+// "folders" s3 consoles create, which is a directory rather than a file.
+func isDirObject(objectName string) bool {
+	return strings.HasSuffix(objectName, "/")
 }
 
 func (localClient *LocalClient) ListBuckets() (*ListBucketsResult, error) {
-	return nil, nil
+	entries, err := ioutil.ReadDir(localClient.rootDir)
+	if err != nil {
+		return nil, err
+	}
+	var bucketNames []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			bucketNames = append(bucketNames, entry.Name())
+		}
+	}
+	return &ListBucketsResult{BucketNames: bucketNames}, nil
 }
 
 func (localClient *LocalClient) CheckBucketExist(dirName string) (bool, error) {
-	return false, nil
+	info, err := os.Stat(localClient.bucketPath(dirName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return info.IsDir(), nil
 }
 
 func (localClient *LocalClient) CreateBucket(dirName string) error {
-	return nil
+	return os.MkdirAll(localClient.bucketPath(dirName), 0755)
 }
 
-func (localClient *LocalClient) UploadFile(urlType UrlType, urlStr, dstBucketName, dstObjectName string) error {
+// UploadFile writes the object to a temporary file next to its destination and
+// renames it into place, so the destination never holds a partial object. Files
+// have no metadata, opts are ignored.
+func (localClient *LocalClient) UploadFile(urlType UrlType, urlStr, dstBucketName, dstObjectName string, opts *UploadOptions) error {
+	path, err := localClient.objectPath(dstBucketName, dstObjectName)
+	if err != nil {
+		return err
+	}
+	if isDirObject(dstObjectName) {
+		return os.MkdirAll(path, 0755)
+	}
+
+	body, _, err := OpenUrlData(urlType, urlStr)
+	if err != nil {
+		logrus.Errorf("get object data failed, error: %v", err)
+		return err
+	}
+	defer closeBody(body)
+
+	dir, fileName := filepath.Split(path)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(dir, "."+fileName+".*"+localTempSuffix)
+	if err != nil {
+		return err
+	}
+	tmpPath := file.Name()
+	if _, err = io.Copy(file, body); err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	logrus.Infof("write object successful, dir: %s, object name: %s", dstBucketName, dstObjectName)
 	return nil
 }
 
 func (localClient *LocalClient) GetObjectUrl(dirName, objectName string) (string, UrlType, error) {
+	objectName, err := localClient.objectPath(dirName, objectName)
+	if err != nil {
+		return "", LocalUrl, err
+	}
 	if filepath.IsAbs(objectName) {
 		return objectName, LocalUrl, nil
 	} else {
@@ -38,28 +158,175 @@ func (localClient *LocalClient) GetObjectUrl(dirName, objectName string) (string
 	}
 }
 
+// ListObjects lists the files of the bucket directory in key order, a page of at
+// most pageSize objects at a time. Directories that only hold keys up
+// to the marker or outside of the prefix are not read at all.
 func (localClient *LocalClient) ListObjects(dirName, marker, prefix string) (*ListObjectsResult, error) {
-	var objectsName []string
-	err := filepath.Walk(dirName,
-		func(path string, info os.FileInfo, err error) error {
+	localClient.lock.Lock()
+	defer localClient.lock.Unlock()
+
+	lister := &localLister{
+		marker:   marker,
+		prefix:   prefix,
+		pageSize: localClient.pageSize,
+		openDirs: make(map[string][]localDirEntry),
+	}
+	// the directories of the previous page are only reused to continue it
+	if marker != "" {
+		lister.cachedDirs = localClient.openDirs
+	}
+	err := lister.walk(localClient.bucketPath(dirName), "")
+	localClient.openDirs = lister.openDirs
+	if err != nil && err != errLocalPageFull {
+		logrus.Errorf("recursive list files failed, dirname: %s, error: %s", dirName, err)
+		return nil, err
+	}
+
+	suspendValue := err == nil
+	nextMarker := ""
+	if !suspendValue {
+		nextMarker = lister.objects[len(lister.objects)-1].Key
+	}
+	return &ListObjectsResult{
+		Objects:    lister.objects,
+		Suspend:    &suspendValue,
+		NextMarker: &nextMarker,
+	}, nil
+}
+
+// errLocalPageFull stops a localLister walk once a page is complete.
+var errLocalPageFull = errors.New("local list page is full")
+
+// localDirEntry is a directory entry, the name of sub directories has a trailing
+// slash so entries sort in the order of the keys below them.
+type localDirEntry struct {
+	name  string
+	entry os.DirEntry
+}
+
+// localLister collects a single ListObjects page.
+type localLister struct {
+	marker   string
+	prefix   string
+	pageSize int
+	objects  []ObjectInfo
+
+	// cachedDirs are the directories the previous page stopped in, openDirs
+	// the ones this page stops in
+	cachedDirs map[string][]localDirEntry
+	openDirs   map[string][]localDirEntry
+}
+
+func (lister *localLister) readDir(dirPath string) ([]localDirEntry, error) {
+	if entries, ok := lister.cachedDirs[dirPath]; ok {
+		return entries, nil
+	}
+	dirEntries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]localDirEntry, 0, len(dirEntries))
+	for _, entry := range dirEntries {
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		entries = append(entries, localDirEntry{name: name, entry: entry})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].name < entries[j].name
+	})
+	return entries, nil
+}
+
+// wantDir reports whether the directory holding the keys starting with dirKey
+// may hold keys after the marker that match the prefix.
+func (lister *localLister) wantDir(dirKey string) bool {
+	if !strings.HasPrefix(dirKey, lister.prefix) && !strings.HasPrefix(lister.prefix, dirKey) {
+		return false
+	}
+	return dirKey > lister.marker || strings.HasPrefix(lister.marker, dirKey)
+}
+
+func (lister *localLister) walk(dirPath, dirKey string) error {
+	entries, err := lister.readDir(dirPath)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		key := dirKey + entry.name
+		if entry.entry.IsDir() {
+			if !lister.wantDir(key) {
+				continue
+			}
+			err = lister.walk(filepath.Join(dirPath, entry.entry.Name()), key)
+			if err == errLocalPageFull {
+				lister.openDirs[dirPath] = entries
+			}
 			if err != nil {
 				return err
 			}
-			if !info.IsDir() {
-				objectsName = append(objectsName, path)
+			continue
+		}
+		if key <= lister.marker || !strings.HasPrefix(key, lister.prefix) || strings.HasSuffix(key, localTempSuffix) {
+			continue
+		}
+		if len(lister.objects) == lister.pageSize {
+			lister.openDirs[dirPath] = entries
+			return errLocalPageFull
+		}
+		info, err := entry.entry.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
 			}
-			return nil
+			return err
+		}
+		lister.objects = append(lister.objects, ObjectInfo{
+			Key:          key,
+			Size:         info.Size(),
+			LastModified: info.ModTime(),
 		})
+	}
+	return nil
+}
+
+func (localClient *LocalClient) StatObject(dirName, objectName string) (*ObjectInfo, error) {
+	path, err := localClient.objectPath(dirName, objectName)
 	if err != nil {
-		logrus.Errorf("recursive list files failed, dirname: %s, error: %s", dirName, err)
 		return nil, err
 	}
-	suspendValue := true
-	nextMarker := ""
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	if info.IsDir() != isDirObject(objectName) {
+		return nil, ErrObjectNotFound
+	}
+	if info.IsDir() {
+		return &ObjectInfo{Key: objectName, LastModified: info.ModTime()}, nil
+	}
 
-	return &ListObjectsResult{
-		ObjectsName: objectsName,
-		Suspend:     &suspendValue,
-		NextMarker:  &nextMarker,
+	// the content type of a file is guessed from its extension
+	return &ObjectInfo{
+		Key:          objectName,
+		Size:         info.Size(),
+		LastModified: info.ModTime(),
+		Meta:         &ObjectMeta{ContentType: mime.TypeByExtension(filepath.Ext(path))},
 	}, nil
 }
+
+func (localClient *LocalClient) DeleteObject(dirName, objectName string) error {
+	path, err := localClient.objectPath(dirName, objectName)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
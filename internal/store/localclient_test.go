@@ -0,0 +1,208 @@
+package store
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestLocalObjectPath(t *testing.T) {
+	rootDir := t.TempDir()
+	client, _ := NewLocalClient(rootDir)
+
+	tests := []struct {
+		objectName string
+		want       string
+		wantErr    bool
+	}{
+		{objectName: "a.txt", want: "bucket/a.txt"},
+		{objectName: "dir/sub/a.txt", want: "bucket/dir/sub/a.txt"},
+		{objectName: "dir/", want: "bucket/dir"},
+		{objectName: "a..b/c..", want: "bucket/a..b/c.."},
+		{objectName: "../x", wantErr: true},
+		{objectName: "dir/../../x", wantErr: true},
+		{objectName: "dir/..", wantErr: true},
+		{objectName: "..", wantErr: true},
+		{objectName: "/etc/x", wantErr: true},
+		{objectName: "//etc/x", wantErr: true},
+		{objectName: "", wantErr: true},
+		{objectName: "/", wantErr: true},
+	}
+
+	for _, test := range tests {
+		path, err := client.objectPath("bucket", test.objectName)
+		if (err != nil) != test.wantErr {
+			t.Errorf("objectPath(%q) error = %v, want error: %v", test.objectName, err, test.wantErr)
+			continue
+		}
+		if !test.wantErr && path != filepath.Join(rootDir, filepath.FromSlash(test.want)) {
+			t.Errorf("objectPath(%q) = %q, want %q under %q", test.objectName, path, test.want, rootDir)
+		}
+	}
+}
+
+func TestLocalUploadFileRejectsEscapingKeys(t *testing.T) {
+	rootDir := t.TempDir()
+	client, _ := NewLocalClient(filepath.Join(rootDir, "root"))
+	sourceFile := filepath.Join(rootDir, "source.txt")
+	if err := ioutil.WriteFile(sourceFile, []byte("content"), 0644); err != nil {
+		t.Fatalf("write source file failed, error: %v", err)
+	}
+
+	for _, objectName := range []string{"../x", "../../x", filepath.ToSlash(filepath.Join(rootDir, "x"))} {
+		if err := client.UploadFile(LocalUrl, sourceFile, "bucket", objectName, nil); err == nil {
+			t.Errorf("upload of %q succeeded, want an error", objectName)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(rootDir, "x")); !os.IsNotExist(err) {
+		t.Errorf("upload wrote outside of the bucket directory, stat error: %v", err)
+	}
+}
+
+func TestLocalUploadFileInterrupted(t *testing.T) {
+	// the server announces more bytes than it sends, so the copy of the body fails
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1024")
+		w.Write([]byte("partial content"))
+	}))
+	defer server.Close()
+
+	rootDir := t.TempDir()
+	client, _ := NewLocalClient(rootDir)
+	dir := filepath.Join(rootDir, "bucket", "dir")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("create bucket directory failed, error: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "old.txt"), []byte("old content"), 0644); err != nil {
+		t.Fatalf("write old object failed, error: %v", err)
+	}
+
+	for _, objectName := range []string{"dir/new.txt", "dir/old.txt"} {
+		if err := client.UploadFile(HttpUrl, server.URL, "bucket", objectName, nil); err == nil {
+			t.Errorf("interrupted upload of %q succeeded, want an error", objectName)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "new.txt")); !os.IsNotExist(err) {
+		t.Errorf("interrupted upload left a file behind, stat error: %v", err)
+	}
+	if content, err := ioutil.ReadFile(filepath.Join(dir, "old.txt")); err != nil || string(content) != "old content" {
+		t.Errorf("interrupted upload changed the existing object to %q, error: %v", content, err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("directory holds %d entries, error: %v, want only the old object", len(entries), err)
+	}
+}
+
+// listPages lists a bucket from marker to its end and returns the keys of every
+// page.
+func listPages(t *testing.T, client *LocalClient, marker, prefix string) [][]string {
+	var pages [][]string
+	for {
+		result, err := client.ListObjects("bucket", marker, prefix)
+		if err != nil {
+			t.Fatalf("list objects after %q with prefix %q failed, error: %v", marker, prefix, err)
+		}
+		var keys []string
+		for _, object := range result.Objects {
+			keys = append(keys, object.Key)
+		}
+		pages = append(pages, keys)
+		if *result.Suspend {
+			return pages
+		}
+		if *result.NextMarker != keys[len(keys)-1] {
+			t.Fatalf("next marker = %q, want the last key %q", *result.NextMarker, keys[len(keys)-1])
+		}
+		marker = *result.NextMarker
+		if len(pages) > 10 {
+			t.Fatalf("listing after %q with prefix %q does not end", marker, prefix)
+		}
+	}
+}
+
+func TestLocalListObjectsPages(t *testing.T) {
+	rootDir := t.TempDir()
+	keys := []string{"a.b", "a/b", "a/c/d", "a/c/e", "a/c0", "a0", "b/x", "b/y/z", "c"}
+	for _, key := range append(keys, "a/c/.d."+localTempSuffix) {
+		path := filepath.Join(rootDir, "bucket", filepath.FromSlash(key))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(key), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		marker string
+		prefix string
+		want   [][]string
+	}{
+		{
+			// "a.b" sorts before "a/b" since '.' < '/', and "a/c/e" before "a/c0"
+			// since '/' < '0'
+			name: "whole bucket",
+			want: [][]string{{"a.b", "a/b", "a/c/d"}, {"a/c/e", "a/c0", "a0"}, {"b/x", "b/y/z", "c"}},
+		},
+		{
+			name:   "marker in a directory",
+			marker: "a/c/d",
+			want:   [][]string{{"a/c/e", "a/c0", "a0"}, {"b/x", "b/y/z", "c"}},
+		},
+		{
+			name:   "marker of a missing key",
+			marker: "a/bb",
+			want:   [][]string{{"a/c/d", "a/c/e", "a/c0"}, {"a0", "b/x", "b/y/z"}, {"c"}},
+		},
+		{
+			name:   "directory prefix",
+			prefix: "a/",
+			want:   [][]string{{"a/b", "a/c/d", "a/c/e"}, {"a/c0"}},
+		},
+		{
+			name:   "key prefix",
+			prefix: "a/c",
+			want:   [][]string{{"a/c/d", "a/c/e", "a/c0"}},
+		},
+		{
+			name:   "prefix and marker",
+			marker: "a/c/d",
+			prefix: "a/c",
+			want:   [][]string{{"a/c/e", "a/c0"}},
+		},
+		{
+			name:   "prefix of no key",
+			prefix: "d",
+			want:   [][]string{nil},
+		},
+	}
+
+	for _, test := range tests {
+		// a new client for every listing, so resuming does not depend on the
+		// directories cached by an earlier page
+		client, _ := NewLocalClient(rootDir)
+		client.pageSize = 3
+		got := listPages(t, client, test.marker, test.prefix)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: pages = %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	var all []string
+	client, _ := NewLocalClient(rootDir)
+	client.pageSize = 3
+	for _, page := range listPages(t, client, "", "") {
+		all = append(all, page...)
+	}
+	if !sort.StringsAreSorted(all) || !reflect.DeepEqual(all, keys) {
+		t.Errorf("listed keys %v, want the keys in s3 order %v", all, keys)
+	}
+}
@@ -5,16 +5,40 @@ import (
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 	"github.com/sirupsen/logrus"
 	"github.com/wonderivan/logger"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 type OssConfig struct {
 	AccessID  string
 	AccessKey string
 	EndPoint  string
+	Multipart MultipartConfig
 }
 
+const (
+	// maxOssCopyObjectSize is the largest object a single CopyObject copies
+	maxOssCopyObjectSize int64 = 1024 * 1024 * 1024
+	ossCopyPartSize      int64 = 100 * 1024 * 1024
+	// ossMaxParts is the largest part count of a multipart upload or copy
+	ossMaxParts = 10000
+
+	// ossLifecycleDateFormat is the format of the dates of lifecycle rules,
+	// which are midnight UTC
+	ossLifecycleDateFormat = "2006-01-02T00:00:00.000Z"
+	ossSSEAlgorithmKMS     = "KMS"
+	ossLifecycleEnabled    = "Enabled"
+	ossLifecycleDisabled   = "Disabled"
+)
+
 type OssClient struct {
 	*oss.Client
+	multipart MultipartConfig
 }
 
 func NewOssClient(cfg *OssConfig) (*OssClient, error) {
@@ -22,18 +46,28 @@ func NewOssClient(cfg *OssConfig) (*OssClient, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &OssClient{Client: client}, nil
-}
-
-func (ossClient *OssClient) ListBuckets() (*ListBucketsResult, error) {
-	lbr, err := ossClient.Client.ListBuckets()
-	if err != nil {
+	ossClient := &OssClient{Client: client, multipart: withMultipartDefaults(cfg.Multipart)}
+	if err = ossClient.multipart.validate(); err != nil {
 		return nil, err
 	}
+	return ossClient, nil
+}
 
+func (ossClient *OssClient) ListBuckets() (*ListBucketsResult, error) {
 	var bucketNames []string
-	for _, bucket := range lbr.Buckets {
-		bucketNames = append(bucketNames, bucket.Name)
+	marker := ""
+	for {
+		lbr, err := ossClient.Client.ListBuckets(oss.Marker(marker))
+		if err != nil {
+			return nil, err
+		}
+		for _, bucket := range lbr.Buckets {
+			bucketNames = append(bucketNames, bucket.Name)
+		}
+		if !lbr.IsTruncated {
+			break
+		}
+		marker = lbr.NextMarker
 	}
 	return &ListBucketsResult{
 		BucketNames: bucketNames,
@@ -41,11 +75,7 @@ func (ossClient *OssClient) ListBuckets() (*ListBucketsResult, error) {
 }
 
 func (ossClient *OssClient) CheckBucketExist(bucketName string) (bool, error) {
-	bucket, err := ossClient.Client.Bucket(bucketName)
-	if err != nil {
-		return false, err
-	}
-	return bucket != nil, nil
+	return ossClient.Client.IsBucketExist(bucketName)
 }
 
 func (ossClient *OssClient) CreateBucket(bucketName string) error {
@@ -54,19 +84,190 @@ func (ossClient *OssClient) CreateBucket(bucketName string) error {
 	return err
 }
 
-func (ossClient *OssClient) UploadFile(urlType UrlType, urlStr, dstBucketName, dstObjectName string) error {
-	data, err := ReadUrlData(urlType, urlStr)
+func (ossClient *OssClient) UploadFile(urlType UrlType, urlStr, dstBucketName, dstObjectName string, opts *UploadOptions) error {
+	bucket, err := ossClient.Client.Bucket(dstBucketName)
+	if err != nil {
+		return err
+	}
+
+	body, size, err := OpenUrlData(urlType, urlStr)
 	if err != nil {
 		logrus.Errorf("get object data failed, error: %v", err)
 		return err
 	}
+	defer closeBody(body)
+
+	options := ossUploadOptions(opts)
+	if size < 0 {
+		return bucket.PutObject(dstObjectName, body, options...)
+	}
+	if size >= ossClient.multipart.Threshold {
+		return ossClient.uploadMultipart(bucket, body, size, dstObjectName, options)
+	}
+	// a limited reader lets the oss sdk send the content length up front
+	// instead of buffering the body to measure it
+	options = append(options, oss.ContentLength(size))
+	return bucket.PutObject(dstObjectName, &io.LimitedReader{R: body, N: size}, options...)
+}
+
+// uploadMultipart streams body to an object in parts of the multipart part size,
+// or larger ones when the object would need more than ossMaxParts parts. The
+// parts are read one after another and sent Concurrency at a time, so at most
+// Concurrency parts are held in memory. The upload is aborted when a part fails.
+func (ossClient *OssClient) uploadMultipart(bucket *oss.Bucket, body io.Reader, size int64, objectName string, options []oss.Option) error {
+	partSize := ossClient.multipart.PartSize
+	if size/partSize >= ossMaxParts {
+		partSize = size/ossMaxParts + 1
+	}
+	imur, err := bucket.InitiateMultipartUpload(objectName, options...)
+	if err != nil {
+		return err
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mutex     sync.Mutex
+		parts     []oss.UploadPart
+		uploadErr error
+	)
+	failed := func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return uploadErr != nil
+	}
+	slots := make(chan struct{}, ossClient.multipart.Concurrency)
+	for partNumber, offset := 1, int64(0); offset < size && !failed(); partNumber++ {
+		slots <- struct{}{}
+		data := make([]byte, partSize)
+		if size-offset < partSize {
+			data = data[:size-offset]
+		}
+		if _, err = io.ReadFull(body, data); err != nil {
+			<-slots
+			break
+		}
+		offset += int64(len(data))
+
+		wg.Add(1)
+		go func(partNumber int, data []byte) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			part, err := bucket.UploadPart(imur, bytes.NewReader(data), int64(len(data)), partNumber)
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				if uploadErr == nil {
+					uploadErr = err
+				}
+				return
+			}
+			parts = append(parts, part)
+		}(partNumber, data)
+	}
+	wg.Wait()
+	if err == nil {
+		err = uploadErr
+	}
+	if err == nil {
+		sort.Slice(parts, func(i, j int) bool {
+			return parts[i].PartNumber < parts[j].PartNumber
+		})
+		_, err = bucket.CompleteMultipartUpload(imur, parts)
+	}
+	if err != nil {
+		// no orphaned parts are left behind in the target bucket
+		if abortErr := bucket.AbortMultipartUpload(imur); abortErr != nil {
+			logrus.Errorf("abort multipart upload failed, object name: %s, error: %v", objectName, abortErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// ossUploadOptions translates opts to the options of an oss upload.
+func ossUploadOptions(opts *UploadOptions) []oss.Option {
+	if opts == nil {
+		return nil
+	}
+
+	var options []oss.Option
+	if opts.StorageClass != "" {
+		options = append(options, oss.ObjectStorageClass(oss.StorageClassType(opts.StorageClass)))
+	}
+	if len(opts.Tags) > 0 {
+		tagging := oss.Tagging{}
+		for key, value := range opts.Tags {
+			tagging.Tags = append(tagging.Tags, oss.Tag{Key: key, Value: value})
+		}
+		options = append(options, oss.SetTagging(tagging))
+	}
+	if opts.Meta == nil {
+		return options
+	}
+
+	meta := opts.Meta
+	if meta.ContentType != "" {
+		options = append(options, oss.ContentType(meta.ContentType))
+	}
+	if meta.ContentEncoding != "" {
+		options = append(options, oss.ContentEncoding(meta.ContentEncoding))
+	}
+	if meta.ContentDisposition != "" {
+		options = append(options, oss.ContentDisposition(meta.ContentDisposition))
+	}
+	if meta.ContentLanguage != "" {
+		options = append(options, oss.ContentLanguage(meta.ContentLanguage))
+	}
+	if meta.CacheControl != "" {
+		options = append(options, oss.CacheControl(meta.CacheControl))
+	}
+	if expires := meta.expiresTime(); expires != nil {
+		options = append(options, oss.Expires(*expires))
+	}
+	for key, value := range meta.UserMeta {
+		options = append(options, oss.Meta(key, value))
+	}
+	return options
+}
 
+func (ossClient *OssClient) CanCopyFrom(source Store) bool {
+	sourceClient, ok := source.(*OssClient)
+	return ok && sourceClient.Config.Endpoint == ossClient.Config.Endpoint &&
+		sourceClient.Config.AccessKeyID == ossClient.Config.AccessKeyID
+}
+
+// CopyObject copies an object server side, with a single CopyObject up to
+// maxOssCopyObjectSize and a multipart copy above.
+func (ossClient *OssClient) CopyObject(srcBucketName, srcObjectName, dstBucketName, dstObjectName string, size int64, opts *UploadOptions) error {
 	bucket, err := ossClient.Client.Bucket(dstBucketName)
 	if err != nil {
 		return err
 	}
 
-	return bucket.PutObject(dstObjectName, bytes.NewReader(data))
+	options := ossUploadOptions(opts)
+	if size <= maxOssCopyObjectSize {
+		if opts != nil && opts.Meta != nil {
+			options = append(options, oss.MetadataDirective(oss.MetaReplace))
+		}
+		if opts != nil && opts.Tags != nil {
+			options = append(options, oss.TaggingDirective(oss.TaggingReplace))
+		}
+		_, err = bucket.CopyObjectFrom(srcBucketName, srcObjectName, dstObjectName, options...)
+	} else {
+		partSize := ossCopyPartSize
+		if partSize < size/ossMaxParts+1 {
+			partSize = size/ossMaxParts + 1
+		}
+		options = append(options, oss.Routines(ossClient.multipart.Concurrency))
+		err = bucket.CopyFile(srcBucketName, srcObjectName, dstObjectName, partSize, options...)
+	}
+	if err != nil {
+		logrus.Errorf("copy object failed, source: %s/%s, target: %s/%s, error: %v",
+			srcBucketName, srcObjectName, dstBucketName, dstObjectName, err)
+		return err
+	}
+	logrus.Infof("copy object successful, bucket: %s, object name: %s", dstBucketName, dstObjectName)
+	return nil
 }
 
 func (ossClient *OssClient) GetObjectUrl(bucketName, objectName string) (string, UrlType, error) {
@@ -91,11 +292,17 @@ func (ossClient *OssClient) ListObjects(bucketName, marker, prefix string) (*Lis
 		return nil, err
 	}
 
-	var objectsName []string
+	var objects []ObjectInfo
 	lastKey := ""
 	for _, object := range lor.Objects {
 		lastKey = object.Key
-		objectsName = append(objectsName, object.Key)
+		objects = append(objects, ObjectInfo{
+			Key:          object.Key,
+			Size:         object.Size,
+			ETag:         strings.Trim(object.ETag, `"`),
+			LastModified: object.LastModified,
+			StorageClass: object.StorageClass,
+		})
 	}
 
 	suspendValue := true
@@ -103,8 +310,8 @@ func (ossClient *OssClient) ListObjects(bucketName, marker, prefix string) (*Lis
 	if !lor.IsTruncated {
 		logrus.Infof("suspend listing objects in bucket: %s", bucketName)
 		return &ListObjectsResult{
-			ObjectsName: objectsName,
-			Suspend:     &suspendValue,
+			Objects: objects,
+			Suspend: &suspendValue,
 		}, nil
 	} else {
 		prevMarker := marker
@@ -116,15 +323,425 @@ func (ossClient *OssClient) ListObjects(bucketName, marker, prefix string) (*Lis
 		if marker == prevMarker {
 			logger.Error("Unable to list all bucket objects.")
 			return &ListObjectsResult{
-				ObjectsName: objectsName,
-				Suspend:     &suspendValue,
+				Objects: objects,
+				Suspend: &suspendValue,
 			}, nil
 		} else {
 			return &ListObjectsResult{
-				ObjectsName: objectsName,
-				Suspend:     &nonSuspendValue,
-				NextMarker:  &marker,
+				Objects:    objects,
+				Suspend:    &nonSuspendValue,
+				NextMarker: &marker,
 			}, nil
 		}
 	}
 }
+
+func (ossClient *OssClient) StatObject(bucketName, objectName string) (*ObjectInfo, error) {
+	bucket, err := ossClient.Client.Bucket(bucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := bucket.GetObjectDetailedMeta(objectName)
+	if err != nil {
+		if srvErr, ok := err.(oss.ServiceError); ok && srvErr.StatusCode == http.StatusNotFound {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+
+	size, _ := strconv.ParseInt(header.Get(oss.HTTPHeaderContentLength), 10, 64)
+	lastModified, _ := http.ParseTime(header.Get(oss.HTTPHeaderLastModified))
+	meta := &ObjectMeta{
+		ContentType:        header.Get(oss.HTTPHeaderContentType),
+		ContentEncoding:    header.Get(oss.HTTPHeaderContentEncoding),
+		ContentDisposition: header.Get(oss.HTTPHeaderContentDisposition),
+		ContentLanguage:    header.Get(oss.HTTPHeaderContentLanguage),
+		CacheControl:       header.Get(oss.HTTPHeaderCacheControl),
+		Expires:            header.Get(oss.HTTPHeaderExpires),
+		UserMeta:           make(map[string]string),
+	}
+	metaPrefix := strings.ToLower(oss.HTTPHeaderOssMetaPrefix)
+	for key := range header {
+		if name := strings.ToLower(key); strings.HasPrefix(name, metaPrefix) {
+			meta.UserMeta[strings.TrimPrefix(name, metaPrefix)] = header.Get(key)
+		}
+	}
+	return &ObjectInfo{
+		Key:          objectName,
+		Size:         size,
+		ETag:         strings.Trim(header.Get(oss.HTTPHeaderEtag), `"`),
+		LastModified: lastModified,
+		StorageClass: header.Get(oss.HTTPHeaderOssStorageClass),
+		Meta:         meta,
+	}, nil
+}
+
+func (ossClient *OssClient) DeleteObject(bucketName, objectName string) error {
+	bucket, err := ossClient.Client.Bucket(bucketName)
+	if err != nil {
+		return err
+	}
+
+	return bucket.DeleteObject(objectName)
+}
+
+// GetBucketACL returns the canned ACL of the bucket, the only kind of ACL OSS has.
+func (ossClient *OssClient) GetBucketACL(bucketName string) (*ACL, error) {
+	result, err := ossClient.Client.GetBucketACL(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	return &ACL{Canned: result.ACL, Owner: result.Owner.ID}, nil
+}
+
+// PutBucketACL sets the canned ACL acl amounts to for anonymous access.
+func (ossClient *OssClient) PutBucketACL(bucketName string, acl *ACL) error {
+	return ossClient.Client.SetBucketACL(bucketName, oss.ACLType(acl.cannedACL()))
+}
+
+func (ossClient *OssClient) GetObjectACL(bucketName, objectName string) (*ACL, error) {
+	bucket, err := ossClient.Client.Bucket(bucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := bucket.GetObjectACL(objectName)
+	if err != nil {
+		return nil, err
+	}
+	if result.ACL == string(oss.ACLDefault) {
+		return nil, nil
+	}
+	return &ACL{Canned: result.ACL, Owner: result.Owner.ID}, nil
+}
+
+func (ossClient *OssClient) PutObjectACL(bucketName, objectName string, acl *ACL) error {
+	bucket, err := ossClient.Client.Bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	return bucket.SetObjectACL(objectName, oss.ACLType(acl.cannedACL()))
+}
+
+func (ossClient *OssClient) GetBucketPolicy(bucketName string) (string, error) {
+	policy, err := ossClient.Client.GetBucketPolicy(bucketName)
+	if err != nil {
+		if srvErr, ok := err.(oss.ServiceError); ok && srvErr.Code == "NoSuchBucketPolicy" {
+			return "", nil
+		}
+		return "", err
+	}
+	return policy, nil
+}
+
+func (ossClient *OssClient) PutBucketPolicy(bucketName, policy string) error {
+	return ossClient.Client.SetBucketPolicy(bucketName, policy)
+}
+
+func (ossClient *OssClient) GetBucketCORS(bucketName string) ([]CORSRule, error) {
+	result, err := ossClient.Client.GetBucketCORS(bucketName)
+	if err != nil {
+		if srvErr, ok := err.(oss.ServiceError); ok && srvErr.Code == "NoSuchCORSConfiguration" {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var rules []CORSRule
+	for _, rule := range result.CORSRules {
+		rules = append(rules, CORSRule{
+			AllowedOrigins: rule.AllowedOrigin,
+			AllowedMethods: rule.AllowedMethod,
+			AllowedHeaders: rule.AllowedHeader,
+			ExposeHeaders:  rule.ExposeHeader,
+			MaxAgeSeconds:  rule.MaxAgeSeconds,
+		})
+	}
+	return rules, nil
+}
+
+func (ossClient *OssClient) PutBucketCORS(bucketName string, rules []CORSRule) error {
+	if len(rules) == 0 {
+		return ossClient.Client.DeleteBucketCORS(bucketName)
+	}
+	var ossRules []oss.CORSRule
+	for _, rule := range rules {
+		ossRules = append(ossRules, oss.CORSRule{
+			AllowedOrigin: rule.AllowedOrigins,
+			AllowedMethod: rule.AllowedMethods,
+			AllowedHeader: rule.AllowedHeaders,
+			ExposeHeader:  rule.ExposeHeaders,
+			MaxAgeSeconds: rule.MaxAgeSeconds,
+		})
+	}
+	return ossClient.Client.SetBucketCORS(bucketName, ossRules)
+}
+
+func (ossClient *OssClient) GetObjectTagging(bucketName, objectName string) (map[string]string, error) {
+	bucket, err := ossClient.Client.Bucket(bucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := bucket.GetObjectTagging(objectName)
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string)
+	for _, tag := range result.Tags {
+		tags[tag.Key] = tag.Value
+	}
+	return tags, nil
+}
+
+// hasOssErrorCode reports whether err is an error response with one of codes.
+func hasOssErrorCode(err error, codes ...string) bool {
+	srvErr, ok := err.(oss.ServiceError)
+	if !ok {
+		return false
+	}
+	for _, code := range codes {
+		if srvErr.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (ossClient *OssClient) GetBucketVersioning(bucketName string) (string, error) {
+	result, err := ossClient.Client.GetBucketVersioning(bucketName)
+	if err != nil {
+		return "", err
+	}
+	return result.Status, nil
+}
+
+func (ossClient *OssClient) PutBucketVersioning(bucketName, status string) error {
+	return ossClient.Client.SetBucketVersioning(bucketName, oss.VersioningConfig{Status: status})
+}
+
+// GetBucketLifecycle translates the lifecycle rules of OSS into the S3 shape, the
+// CreatedBeforeDate of OSS is the date of S3.
+func (ossClient *OssClient) GetBucketLifecycle(bucketName string) ([]LifecycleRule, error) {
+	result, err := ossClient.Client.GetBucketLifecycle(bucketName)
+	if err != nil {
+		if hasOssErrorCode(err, "NoSuchLifecycle") {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var rules []LifecycleRule
+	for _, ossRule := range result.Rules {
+		rule := LifecycleRule{
+			ID:      ossRule.ID,
+			Prefix:  ossRule.Prefix,
+			Enabled: ossRule.Status == ossLifecycleEnabled,
+		}
+		for _, tag := range ossRule.Tags {
+			if rule.Tags == nil {
+				rule.Tags = make(map[string]string)
+			}
+			rule.Tags[tag.Key] = tag.Value
+		}
+		if expiration := ossRule.Expiration; expiration != nil {
+			rule.ExpirationDays = expiration.Days
+			rule.ExpirationDate = parseOssLifecycleDate(expiration.CreatedBeforeDate)
+			if rule.ExpirationDate.IsZero() {
+				rule.ExpirationDate = parseOssLifecycleDate(expiration.Date)
+			}
+			rule.ExpiredObjectDeleteMarker = expiration.ExpiredObjectDeleteMarker != nil && *expiration.ExpiredObjectDeleteMarker
+		}
+		for _, transition := range ossRule.Transitions {
+			rule.Transitions = append(rule.Transitions, LifecycleTransition{
+				Days:         transition.Days,
+				Date:         parseOssLifecycleDate(transition.CreatedBeforeDate),
+				StorageClass: string(transition.StorageClass),
+			})
+		}
+		if ossRule.NonVersionExpiration != nil {
+			rule.NoncurrentExpirationDays = ossRule.NonVersionExpiration.NoncurrentDays
+		}
+		for _, transition := range ossRule.NonVersionTransitions {
+			rule.NoncurrentTransitions = append(rule.NoncurrentTransitions, LifecycleTransition{
+				Days:         transition.NoncurrentDays,
+				StorageClass: string(transition.StorageClass),
+			})
+		}
+		if ossRule.AbortMultipartUpload != nil {
+			rule.AbortMultipartUploadDays = ossRule.AbortMultipartUpload.Days
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (ossClient *OssClient) PutBucketLifecycle(bucketName string, rules []LifecycleRule) error {
+	if len(rules) == 0 {
+		return ossClient.Client.DeleteBucketLifecycle(bucketName)
+	}
+
+	var ossRules []oss.LifecycleRule
+	for _, rule := range rules {
+		ossRule := oss.LifecycleRule{
+			ID:     rule.ID,
+			Prefix: rule.Prefix,
+			Status: ossLifecycleDisabled,
+		}
+		if rule.Enabled {
+			ossRule.Status = ossLifecycleEnabled
+		}
+		for key, value := range rule.Tags {
+			ossRule.Tags = append(ossRule.Tags, oss.Tag{Key: key, Value: value})
+		}
+		sort.Slice(ossRule.Tags, func(i, j int) bool {
+			return ossRule.Tags[i].Key < ossRule.Tags[j].Key
+		})
+		if rule.ExpirationDays > 0 || !rule.ExpirationDate.IsZero() || rule.ExpiredObjectDeleteMarker {
+			ossRule.Expiration = &oss.LifecycleExpiration{
+				Days:              rule.ExpirationDays,
+				CreatedBeforeDate: formatOssLifecycleDate(rule.ExpirationDate),
+			}
+			if rule.ExpiredObjectDeleteMarker {
+				deleteMarker := true
+				ossRule.Expiration.ExpiredObjectDeleteMarker = &deleteMarker
+			}
+		}
+		for _, transition := range rule.Transitions {
+			ossRule.Transitions = append(ossRule.Transitions, oss.LifecycleTransition{
+				Days:              transition.Days,
+				CreatedBeforeDate: formatOssLifecycleDate(transition.Date),
+				StorageClass:      oss.StorageClassType(transition.StorageClass),
+			})
+		}
+		if rule.NoncurrentExpirationDays > 0 {
+			ossRule.NonVersionExpiration = &oss.LifecycleVersionExpiration{NoncurrentDays: rule.NoncurrentExpirationDays}
+		}
+		for _, transition := range rule.NoncurrentTransitions {
+			ossRule.NonVersionTransitions = append(ossRule.NonVersionTransitions, oss.LifecycleVersionTransition{
+				NoncurrentDays: transition.Days,
+				StorageClass:   oss.StorageClassType(transition.StorageClass),
+			})
+		}
+		if rule.AbortMultipartUploadDays > 0 {
+			ossRule.AbortMultipartUpload = &oss.LifecycleAbortMultipartUpload{Days: rule.AbortMultipartUploadDays}
+		}
+		ossRules = append(ossRules, ossRule)
+	}
+	return ossClient.Client.SetBucketLifecycle(bucketName, ossRules)
+}
+
+func parseOssLifecycleDate(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		logrus.Warnf("invalid lifecycle date: %q, error: %v", value, err)
+		return time.Time{}
+	}
+	return date
+}
+
+func formatOssLifecycleDate(date time.Time) string {
+	if date.IsZero() {
+		return ""
+	}
+	return date.UTC().Format(ossLifecycleDateFormat)
+}
+
+func (ossClient *OssClient) GetBucketTagging(bucketName string) (map[string]string, error) {
+	result, err := ossClient.Client.GetBucketTagging(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Tags) == 0 {
+		return nil, nil
+	}
+
+	tags := make(map[string]string)
+	for _, tag := range result.Tags {
+		tags[tag.Key] = tag.Value
+	}
+	return tags, nil
+}
+
+func (ossClient *OssClient) PutBucketTagging(bucketName string, tags map[string]string) error {
+	if len(tags) == 0 {
+		return ossClient.Client.DeleteBucketTagging(bucketName)
+	}
+
+	tagging := oss.Tagging{}
+	for key, value := range tags {
+		tagging.Tags = append(tagging.Tags, oss.Tag{Key: key, Value: value})
+	}
+	sort.Slice(tagging.Tags, func(i, j int) bool {
+		return tagging.Tags[i].Key < tagging.Tags[j].Key
+	})
+	return ossClient.Client.SetBucketTagging(bucketName, tagging)
+}
+
+// GetBucketWebsite returns the index and error documents of the website of a
+// bucket, routing rules are not supported.
+func (ossClient *OssClient) GetBucketWebsite(bucketName string) (*WebsiteConfig, error) {
+	result, err := ossClient.Client.GetBucketWebsite(bucketName)
+	if err != nil {
+		if hasOssErrorCode(err, "NoSuchWebsiteConfiguration") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(result.RoutingRules) > 0 {
+		logrus.Warnf("website routing rules are not supported and dropped, bucket: %s", bucketName)
+	}
+	return &WebsiteConfig{
+		IndexDocument: result.IndexDocument.Suffix,
+		ErrorDocument: result.ErrorDocument.Key,
+	}, nil
+}
+
+func (ossClient *OssClient) PutBucketWebsite(bucketName string, website *WebsiteConfig) error {
+	if website == nil {
+		return ossClient.Client.DeleteBucketWebsite(bucketName)
+	}
+	return ossClient.Client.SetBucketWebsite(bucketName, website.IndexDocument, website.ErrorDocument)
+}
+
+// GetBucketEncryption translates the KMS algorithm of OSS into the aws:kms of S3,
+// other algorithms like AES256 and SM4 keep their name.
+func (ossClient *OssClient) GetBucketEncryption(bucketName string) (*BucketEncryption, error) {
+	result, err := ossClient.Client.GetBucketEncryption(bucketName)
+	if err != nil {
+		if hasOssErrorCode(err, "NoSuchServerSideEncryptionRule") {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	encryption := &BucketEncryption{
+		Algorithm: result.SSEDefault.SSEAlgorithm,
+		KMSKeyID:  result.SSEDefault.KMSMasterKeyID,
+	}
+	if encryption.Algorithm == ossSSEAlgorithmKMS {
+		encryption.Algorithm = SSEAlgorithmKMS
+	}
+	return encryption, nil
+}
+
+func (ossClient *OssClient) PutBucketEncryption(bucketName string, encryption *BucketEncryption) error {
+	if encryption == nil {
+		return ossClient.Client.DeleteBucketEncryption(bucketName)
+	}
+
+	algorithm := encryption.Algorithm
+	if algorithm == SSEAlgorithmKMS {
+		algorithm = ossSSEAlgorithmKMS
+	}
+	return ossClient.Client.SetBucketEncryption(bucketName, oss.ServerEncryptionRule{
+		SSEDefault: oss.SSEDefaultRule{
+			SSEAlgorithm:   algorithm,
+			KMSMasterKeyID: encryption.KMSKeyID,
+		},
+	})
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeOssServer keeps the objects of one bucket written with PutObject or a
+// multipart upload.
+type fakeOssServer struct {
+	mutex    sync.Mutex
+	objects  map[string][]byte
+	parts    map[int][]byte
+	puts     int
+	aborted  bool
+	failPart int
+}
+
+func (server *fakeOssServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	query := r.URL.Query()
+	key := strings.TrimPrefix(r.URL.Path, "/test-bucket/")
+	body, _ := ioutil.ReadAll(r.Body)
+	switch {
+	case r.Method == http.MethodPost && r.URL.RawQuery == "uploads":
+		server.parts = make(map[int][]byte)
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><Bucket>test-bucket</Bucket><Key>%s</Key>"+
+			"<UploadId>upload-id</UploadId></InitiateMultipartUploadResult>", key)
+	case r.Method == http.MethodPut && query.Get("uploadId") != "":
+		partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+		if partNumber == server.failPart {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		server.parts[partNumber] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, partNumber))
+	case r.Method == http.MethodPost && query.Get("uploadId") != "":
+		var complete struct {
+			Parts []struct {
+				PartNumber int
+				ETag       string
+			} `xml:"Part"`
+		}
+		if err := xml.Unmarshal(body, &complete); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var content []byte
+		for i, part := range complete.Parts {
+			if part.PartNumber != i+1 || part.ETag != fmt.Sprintf(`"etag-%d"`, i+1) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			content = append(content, server.parts[part.PartNumber]...)
+		}
+		server.objects[key] = content
+		fmt.Fprintf(w, "<CompleteMultipartUploadResult><Key>%s</Key></CompleteMultipartUploadResult>", key)
+	case r.Method == http.MethodDelete && query.Get("uploadId") != "":
+		server.aborted = true
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		server.puts++
+		server.objects[key] = body
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func TestOssUploadFileMultipart(t *testing.T) {
+	const partSize = 5 * 1024 * 1024
+	tests := []struct {
+		name      string
+		size      int
+		threshold int64
+		failPart  int
+		wantParts int
+		wantErr   bool
+	}{
+		{name: "below threshold", size: partSize - 1, threshold: partSize},
+		{name: "one part", size: partSize, threshold: partSize, wantParts: 1},
+		{name: "last part smaller", size: 2*partSize + 1024, threshold: partSize, wantParts: 3},
+		{name: "whole parts", size: 2 * partSize, threshold: partSize, wantParts: 2},
+		{name: "failed part", size: 3 * partSize, threshold: partSize, failPart: 2, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := &fakeOssServer{objects: make(map[string][]byte), failPart: test.failPart}
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			client, err := NewOssClient(&OssConfig{
+				AccessID:  "test-access-id",
+				AccessKey: "test-access-key",
+				EndPoint:  server.URL,
+				Multipart: MultipartConfig{PartSize: partSize, Concurrency: 2, Threshold: test.threshold},
+			})
+			if err != nil {
+				t.Fatalf("new oss client failed, error: %v", err)
+			}
+			// fail fast instead of retrying failed parts
+			client.Client.Config.RetryTimes = 0
+
+			content := bytes.Repeat([]byte("0123456789abcdef"), test.size/16+1)[:test.size]
+			fileName := filepath.Join(t.TempDir(), "object")
+			if err = ioutil.WriteFile(fileName, content, os.ModePerm); err != nil {
+				t.Fatalf("write file failed, error: %v", err)
+			}
+
+			err = client.UploadFile(LocalUrl, fileName, "test-bucket", "dir/object", nil)
+			if test.wantErr {
+				if err == nil || !fake.aborted || fake.objects["dir/object"] != nil {
+					t.Errorf("upload error = %v, aborted: %v, want a failed and aborted upload", err, fake.aborted)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("upload failed, error: %v", err)
+			}
+			if !bytes.Equal(fake.objects["dir/object"], content) {
+				t.Errorf("uploaded %d bytes differ from the %d bytes of the file", len(fake.objects["dir/object"]), len(content))
+			}
+			if len(fake.parts) != test.wantParts || (test.wantParts == 0) != (fake.puts == 1) {
+				t.Errorf("uploaded in %d parts and %d puts, want %d parts", len(fake.parts), fake.puts, test.wantParts)
+			}
+		})
+	}
+}
@@ -0,0 +1,1232 @@
+package store
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultS3Region string = "us-east-1"
+
+const (
+	DefaultMultipartPartSize    int64 = 16 * 1024 * 1024
+	DefaultMultipartConcurrency       = 4
+	DefaultMultipartThreshold   int64 = 64 * 1024 * 1024
+)
+
+// MultipartConfig controls how objects are split when they are uploaded.
+// Objects smaller than Threshold are sent with a single PUT, larger ones are
+// sent in parts of PartSize bytes, Concurrency parts at a time.
+type MultipartConfig struct {
+	PartSize    int64
+	Concurrency int
+	Threshold   int64
+}
+
+const (
+	PathStyle    = "path"
+	VirtualStyle = "virtual"
+
+	SignatureV2 = "v2"
+	SignatureV4 = "v4"
+)
+
+// S3Config configures a client of any s3 compatible store, like AWS S3, MinIO
+// or Ceph RGW. An empty EndPoint uses the AWS endpoint of Region, and an empty
+// AddressingStyle is VirtualStyle for AWS and PathStyle for any other endpoint.
+type S3Config struct {
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	EndPoint     string
+	Region       string
+	// AddressingStyle puts the bucket name in the url path with PathStyle, or
+	// in the host name with VirtualStyle
+	AddressingStyle  string
+	SignatureVersion string
+	Multipart        MultipartConfig
+}
+
+type S3Client struct {
+	*s3.S3
+	session   *session.Session
+	uploader  *s3manager.Uploader
+	multipart MultipartConfig
+	region    string
+	endpoint  string
+	accessKey string
+}
+
+func NewS3Client(cfg *S3Config) (*S3Client, error) {
+	s3Client := &S3Client{
+		multipart: withMultipartDefaults(cfg.Multipart),
+		endpoint:  cfg.EndPoint,
+		accessKey: cfg.AccessKey,
+	}
+	if err := s3Client.multipart.validate(); err != nil {
+		return nil, err
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = DefaultS3Region
+	}
+	s3Client.region = region
+	pathStyle := cfg.EndPoint != ""
+	switch strings.ToLower(cfg.AddressingStyle) {
+	case "":
+	case PathStyle:
+		pathStyle = true
+	case VirtualStyle:
+		pathStyle = false
+	default:
+		return nil, fmt.Errorf("unknown addressing style: %q, maybe: path/virtual", cfg.AddressingStyle)
+	}
+
+	var credential = credentials.NewStaticCredentials(cfg.AccessKey, cfg.SecretKey, cfg.SessionToken)
+	var awsConfig = aws.NewConfig().
+		WithRegion(region).
+		WithDisableSSL(false).
+		WithLogLevel(3).
+		WithS3ForcePathStyle(pathStyle).
+		WithCredentials(credential)
+	if cfg.EndPoint != "" {
+		awsConfig = awsConfig.WithEndpoint(cfg.EndPoint)
+	}
+
+	sess, err := session.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	s3Client.session = sess
+	s3Client.S3 = s3.New(s3Client.session, awsConfig)
+
+	switch strings.ToLower(cfg.SignatureVersion) {
+	case "", SignatureV4:
+	case SignatureV2:
+		useSignatureV2(s3Client.S3, credential, pathStyle)
+	default:
+		return nil, fmt.Errorf("unknown signature version: %q, maybe: v2/v4", cfg.SignatureVersion)
+	}
+	// the uploader streams the body in fixed size parts, so memory use does
+	// not depend on the object size
+	s3Client.uploader = s3manager.NewUploaderWithClient(s3Client.S3, func(u *s3manager.Uploader) {
+		u.PartSize = s3Client.multipart.PartSize
+		u.Concurrency = s3Client.multipart.Concurrency
+		// abort the multipart upload when a part fails, so no orphaned
+		// parts are left behind in the target bucket
+		u.LeavePartsOnError = false
+	})
+
+	return s3Client, nil
+}
+
+func withMultipartDefaults(cfg MultipartConfig) MultipartConfig {
+	if cfg.PartSize <= 0 {
+		cfg.PartSize = DefaultMultipartPartSize
+	}
+	if cfg.PartSize < s3manager.MinUploadPartSize {
+		cfg.PartSize = s3manager.MinUploadPartSize
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = DefaultMultipartConcurrency
+	}
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = DefaultMultipartThreshold
+	}
+	return cfg
+}
+
+// validate returns an error when objects from the threshold on would not be
+// larger than a part, the uploader sends those with a single PUT anyway.
+func (cfg MultipartConfig) validate() error {
+	if cfg.Threshold < cfg.PartSize {
+		return fmt.Errorf("multipart threshold of %d bytes is below the part size of %d bytes, objects smaller than a part are not uploaded in parts",
+			cfg.Threshold, cfg.PartSize)
+	}
+	return nil
+}
+
+// partSizeOption adjusts the part size for an object of the given size, -1 if
+// unknown, so large objects get parts big enough to stay within the maximum part
+// count of a multipart upload.
+func (s3Client *S3Client) partSizeOption(size int64) func(*s3manager.Uploader) {
+	return func(u *s3manager.Uploader) {
+		if size >= 0 && size/u.PartSize >= s3manager.MaxUploadParts {
+			u.PartSize = size/s3manager.MaxUploadParts + 1
+		}
+	}
+}
+
+// putObject sends an object below the multipart threshold with a single
+// PutObject. Unlike the uploader, which buffers a whole part, the body is
+// streamed: a body that can't be rewound, like the one of an http response, is
+// sent as an unsigned payload and not retried by the sdk, the copy is retried
+// from the start instead.
+func (s3Client *S3Client) putObject(input *s3manager.UploadInput, size int64) (string, error) {
+	params := &s3.PutObjectInput{}
+	awsutil.Copy(params, input)
+	params.ContentLength = aws.Int64(size)
+	body, seekable := input.Body.(io.ReadSeeker)
+	if !seekable {
+		body = aws.ReadSeekCloser(input.Body)
+	}
+	params.Body = body
+
+	req, output := s3Client.S3.PutObjectRequest(params)
+	if !seekable {
+		req.HTTPRequest.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+		req.Retryable = aws.Bool(false)
+	}
+	if err := req.Send(); err != nil {
+		return "", err
+	}
+	return aws.StringValue(output.VersionId), nil
+}
+
+func (s3Client *S3Client) ListBuckets() (*ListBucketsResult, error) {
+	result, err := s3Client.S3.ListBuckets(nil)
+	if err != nil {
+		logrus.Errorf("list buckets failed, error: %v", err)
+		return nil, err
+	}
+
+	var bucketNames []string
+	for _, b := range result.Buckets {
+		bucket := aws.StringValue(b.Name)
+		logrus.Infof("list bucket, * %s created on %s", bucket, aws.TimeValue(b.CreationDate))
+
+		bucketNames = append(bucketNames, bucket)
+	}
+	return &ListBucketsResult{BucketNames: bucketNames}, nil
+}
+
+func (s3Client *S3Client) CheckBucketExist(bucketName string) (bool, error) {
+	headBucketInput := &s3.HeadBucketInput{
+		Bucket: aws.String(bucketName),
+	}
+	_, err := s3Client.S3.HeadBucket(headBucketInput)
+	if err != nil {
+		logrus.Errorf("check bucket failed, error: %v", err)
+		return false, nil
+	}
+	logrus.Info("check bucket existence successful")
+	return true, nil
+}
+
+func (s3Client *S3Client) CreateBucket(bucketName string) error {
+	params := &s3.CreateBucketInput{
+		Bucket: aws.String(bucketName),
+	}
+	// AWS S3 creates buckets outside the default region only with a location constraint
+	if s3Client.region != DefaultS3Region {
+		params.CreateBucketConfiguration = &s3.CreateBucketConfiguration{
+			LocationConstraint: aws.String(s3Client.region),
+		}
+	}
+	_, err := s3Client.S3.CreateBucket(params)
+	if err != nil {
+		logrus.Errorf("unable to create bucket: %s, %v", bucketName, err)
+		return err
+	}
+	// Wait until bucket is created before finishing
+	logrus.Infof("waiting for bucket %q to be created...", bucketName)
+	err = s3Client.S3.WaitUntilBucketExists(&s3.HeadBucketInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		logrus.Errorf("error occurred while waiting for bucket: %s to be created, error: %v", bucketName, err)
+		return err
+	}
+	logrus.Infof("bucket: %q successfully created...", bucketName)
+	return nil
+}
+
+func (s3Client *S3Client) UploadFile(urlType UrlType, urlStr, dstBucketName, dstObjectName string, opts *UploadOptions) error {
+	_, err := s3Client.upload(urlType, urlStr, dstBucketName, dstObjectName, opts)
+	return err
+}
+
+func (s3Client *S3Client) UploadFileVersion(urlType UrlType, urlStr, dstBucketName, dstObjectName string, opts *UploadOptions) (string, error) {
+	return s3Client.upload(urlType, urlStr, dstBucketName, dstObjectName, opts)
+}
+
+// upload streams the data behind urlStr to an object and returns the version ID
+// of the object, empty when the bucket is not versioned.
+func (s3Client *S3Client) upload(urlType UrlType, urlStr, dstBucketName, dstObjectName string, opts *UploadOptions) (string, error) {
+	body, size, err := OpenUrlData(urlType, urlStr)
+	if err != nil {
+		logrus.Errorf("get object data failed, error: %v", err)
+		return "", err
+	}
+	defer closeBody(body)
+
+	input := &s3manager.UploadInput{
+		Body:   body,
+		Bucket: &dstBucketName,
+		Key:    &dstObjectName,
+	}
+	if opts != nil && opts.Meta != nil {
+		meta := opts.Meta
+		input.ContentType = optionalString(meta.ContentType)
+		input.ContentEncoding = optionalString(meta.ContentEncoding)
+		input.ContentDisposition = optionalString(meta.ContentDisposition)
+		input.ContentLanguage = optionalString(meta.ContentLanguage)
+		input.CacheControl = optionalString(meta.CacheControl)
+		input.Expires = meta.expiresTime()
+		input.Metadata = aws.StringMap(meta.UserMeta)
+	}
+	if opts != nil {
+		input.Tagging = s3Tagging(opts.Tags)
+		input.StorageClass = optionalString(opts.StorageClass)
+	}
+	if size >= 0 && size < s3Client.multipart.Threshold {
+		versionID, err := s3Client.putObject(input, size)
+		if err != nil {
+			logrus.Errorf("upload object failed, bucket: %s, object name: %s", dstBucketName, dstObjectName)
+			return "", err
+		}
+		logrus.Infof("upload object successful, bucket: %s, object name: %s", dstBucketName, dstObjectName)
+		return versionID, nil
+	}
+	output, err := s3Client.uploader.Upload(input, s3Client.partSizeOption(size))
+	if err != nil {
+		if multiErr, ok := err.(s3manager.MultiUploadFailure); ok {
+			logrus.Errorf("multipart upload failed and was aborted, bucket: %s, object name: %s, upload id: %s",
+				dstBucketName, dstObjectName, multiErr.UploadID())
+		} else {
+			logrus.Errorf("upload object failed, bucket: %s, object name: %s", dstBucketName, dstObjectName)
+		}
+		return "", err
+	}
+	logrus.Infof("upload object successful, bucket: %s, object name: %s", dstBucketName, dstObjectName)
+	return aws.StringValue(output.VersionID), nil
+}
+
+func (s3Client *S3Client) GetObjectUrl(bucketName, objectName string) (string, UrlType, error) {
+	return s3Client.GetObjectVersionUrl(bucketName, objectName, "")
+}
+
+// GetObjectVersionUrl signs the url of a version of an object, of the current
+// version when versionID is empty.
+func (s3Client *S3Client) GetObjectVersionUrl(bucketName, objectName, versionID string) (string, UrlType, error) {
+	req, _ := s3Client.S3.GetObjectRequest(&s3.GetObjectInput{
+		Bucket:    aws.String(bucketName),
+		Key:       aws.String(objectName),
+		VersionId: optionalString(versionID),
+	})
+
+	url, err := req.Presign(15 * time.Minute)
+	return url, HttpUrl, err
+}
+
+func (s3Client *S3Client) ListObjects(bucketName, marker, prefix string) (*ListObjectsResult, error) {
+	logrus.Infof("sync bucket: %s, list 1000 objects...", bucketName)
+	listObjectsResponse, err := s3Client.S3.ListObjects(&s3.ListObjectsInput{
+		Bucket: aws.String(bucketName),
+		Marker: aws.String(marker),
+		Prefix: &prefix,
+	})
+	if err != nil {
+		logrus.Errorf("bucket: %s, list objects failed, error: %v", bucketName, err)
+		return nil, err
+	}
+
+	var objects []ObjectInfo
+	lastKey := ""
+	for _, object := range listObjectsResponse.Contents {
+		lastKey = aws.StringValue(object.Key)
+		objects = append(objects, ObjectInfo{
+			Key:          aws.StringValue(object.Key),
+			Size:         aws.Int64Value(object.Size),
+			ETag:         strings.Trim(aws.StringValue(object.ETag), `"`),
+			LastModified: aws.TimeValue(object.LastModified),
+			StorageClass: aws.StringValue(object.StorageClass),
+		})
+	}
+
+	suspendValue := true
+	nonSuspendValue := false
+	if !*listObjectsResponse.IsTruncated {
+		logrus.Infof("suspend listing objects in bucket: %s", bucketName)
+		return &ListObjectsResult{
+			Objects: objects,
+			Suspend: &suspendValue,
+		}, nil
+	} else {
+		prevMarker := marker
+		if listObjectsResponse.NextMarker == nil {
+			// From the s3 docs: If response does not include the
+			// NextMarker and it is truncated, you can use the value of the
+			// last Key in the response as the marker in the subsequent
+			// request to get the next set of object keys.
+			marker = lastKey
+		} else {
+			marker = *listObjectsResponse.NextMarker
+		}
+		if marker == prevMarker {
+			logrus.Error("Unable to list all bucket objects.")
+			return &ListObjectsResult{
+				Objects: objects,
+				Suspend: &suspendValue,
+			}, nil
+		} else {
+			return &ListObjectsResult{
+				Objects:    objects,
+				Suspend:    &nonSuspendValue,
+				NextMarker: &marker,
+			}, nil
+		}
+	}
+}
+
+func (s3Client *S3Client) StatObject(bucketName, objectName string) (*ObjectInfo, error) {
+	return s3Client.StatObjectVersion(bucketName, objectName, "")
+}
+
+// StatObjectVersion reads a version of an object, the current version when
+// versionID is empty.
+func (s3Client *S3Client) StatObjectVersion(bucketName, objectName, versionID string) (*ObjectInfo, error) {
+	output, err := s3Client.S3.HeadObject(&s3.HeadObjectInput{
+		Bucket:    aws.String(bucketName),
+		Key:       aws.String(objectName),
+		VersionId: optionalString(versionID),
+	})
+	if err != nil {
+		if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() == http.StatusNotFound {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+
+	meta := &ObjectMeta{
+		ContentType:        aws.StringValue(output.ContentType),
+		ContentEncoding:    aws.StringValue(output.ContentEncoding),
+		ContentDisposition: aws.StringValue(output.ContentDisposition),
+		ContentLanguage:    aws.StringValue(output.ContentLanguage),
+		CacheControl:       aws.StringValue(output.CacheControl),
+		Expires:            aws.StringValue(output.Expires),
+		UserMeta:           make(map[string]string),
+	}
+	for key, value := range output.Metadata {
+		meta.UserMeta[strings.ToLower(key)] = aws.StringValue(value)
+	}
+	return &ObjectInfo{
+		Key:          objectName,
+		Size:         aws.Int64Value(output.ContentLength),
+		ETag:         strings.Trim(aws.StringValue(output.ETag), `"`),
+		LastModified: aws.TimeValue(output.LastModified),
+		StorageClass: aws.StringValue(output.StorageClass),
+		Meta:         meta,
+	}, nil
+}
+
+// s3Tagging encodes tags for the x-amz-tagging header, nil when there are none.
+func s3Tagging(tags map[string]string) *string {
+	if len(tags) == 0 {
+		return nil
+	}
+	values := url.Values{}
+	for key, value := range tags {
+		values.Set(key, value)
+	}
+	return aws.String(values.Encode())
+}
+
+// optionalString returns nil for an empty string, so the header is not sent.
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return aws.String(value)
+}
+
+func (s3Client *S3Client) DeleteObject(bucketName, objectName string) error {
+	_, err := s3Client.S3.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectName),
+	})
+	if err != nil {
+		logrus.Errorf("delete object failed, bucket: %s, object name: %s, error: %v", bucketName, objectName, err)
+		return err
+	}
+	logrus.Infof("delete object successful, bucket: %s, object name: %s", bucketName, objectName)
+	return nil
+}
+
+// maxCopyObjectSize is the largest object a single CopyObject request copies,
+// larger objects are copied in parts.
+const maxCopyObjectSize int64 = 5 * 1024 * 1024 * 1024
+
+func (s3Client *S3Client) CanCopyFrom(source Store) bool {
+	sourceClient, ok := source.(*S3Client)
+	return ok && sourceClient.endpoint == s3Client.endpoint &&
+		sourceClient.region == s3Client.region && sourceClient.accessKey == s3Client.accessKey
+}
+
+// CopyObject copies an object server side, with a single CopyObject up to 5 GB
+// and a multipart upload of UploadPartCopy requests above. A single CopyObject
+// keeps the metadata of the source object unless opts replace it.
+func (s3Client *S3Client) CopyObject(srcBucketName, srcObjectName, dstBucketName, dstObjectName string, size int64, opts *UploadOptions) error {
+	var err error
+	if size <= maxCopyObjectSize {
+		input := &s3.CopyObjectInput{
+			Bucket:     aws.String(dstBucketName),
+			Key:        aws.String(dstObjectName),
+			CopySource: aws.String(copySource(srcBucketName, srcObjectName)),
+		}
+		if opts != nil && opts.Meta != nil {
+			meta := opts.Meta
+			input.MetadataDirective = aws.String(s3.MetadataDirectiveReplace)
+			input.ContentType = optionalString(meta.ContentType)
+			input.ContentEncoding = optionalString(meta.ContentEncoding)
+			input.ContentDisposition = optionalString(meta.ContentDisposition)
+			input.ContentLanguage = optionalString(meta.ContentLanguage)
+			input.CacheControl = optionalString(meta.CacheControl)
+			input.Expires = meta.expiresTime()
+			input.Metadata = aws.StringMap(meta.UserMeta)
+		}
+		if opts != nil && opts.Tags != nil {
+			input.TaggingDirective = aws.String(s3.TaggingDirectiveReplace)
+			input.Tagging = s3Tagging(opts.Tags)
+		}
+		if opts != nil {
+			input.StorageClass = optionalString(opts.StorageClass)
+		}
+		_, err = s3Client.S3.CopyObject(input)
+	} else {
+		err = s3Client.copyObjectParts(srcBucketName, srcObjectName, dstBucketName, dstObjectName, size, opts)
+	}
+	if err != nil {
+		logrus.Errorf("copy object failed, source: %s/%s, target: %s/%s, error: %v",
+			srcBucketName, srcObjectName, dstBucketName, dstObjectName, err)
+		return err
+	}
+	logrus.Infof("copy object successful, bucket: %s, object name: %s", dstBucketName, dstObjectName)
+	return nil
+}
+
+// copyObjectParts copies an object with a multipart upload, Concurrency parts at
+// a time. The upload is aborted when a part fails.
+func (s3Client *S3Client) copyObjectParts(srcBucketName, srcObjectName, dstBucketName, dstObjectName string, size int64, opts *UploadOptions) error {
+	input := &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(dstBucketName),
+		Key:    aws.String(dstObjectName),
+	}
+	if opts != nil && opts.Meta != nil {
+		meta := opts.Meta
+		input.ContentType = optionalString(meta.ContentType)
+		input.ContentEncoding = optionalString(meta.ContentEncoding)
+		input.ContentDisposition = optionalString(meta.ContentDisposition)
+		input.ContentLanguage = optionalString(meta.ContentLanguage)
+		input.CacheControl = optionalString(meta.CacheControl)
+		input.Expires = meta.expiresTime()
+		input.Metadata = aws.StringMap(meta.UserMeta)
+	}
+	if opts != nil {
+		input.Tagging = s3Tagging(opts.Tags)
+		input.StorageClass = optionalString(opts.StorageClass)
+	}
+	upload, err := s3Client.S3.CreateMultipartUpload(input)
+	if err != nil {
+		return err
+	}
+
+	// copied parts may be up to 5 GB, so they are never smaller than a
+	// thousandth of the object
+	partSize := s3Client.multipart.PartSize
+	if partSize < size/1000+1 {
+		partSize = size/1000 + 1
+	}
+	partCount := int((size + partSize - 1) / partSize)
+	parts := make([]*s3.CompletedPart, partCount)
+	partNumbers := make(chan int, partCount)
+	for i := 0; i < partCount; i++ {
+		partNumbers <- i + 1
+	}
+	close(partNumbers)
+
+	var lock sync.Mutex
+	var partErr error
+	var wg sync.WaitGroup
+	for i := 0; i < s3Client.multipart.Concurrency && i < partCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for partNumber := range partNumbers {
+				lock.Lock()
+				failed := partErr != nil
+				lock.Unlock()
+				if failed {
+					return
+				}
+
+				first := int64(partNumber-1) * partSize
+				last := first + partSize - 1
+				if last >= size {
+					last = size - 1
+				}
+				output, err := s3Client.S3.UploadPartCopy(&s3.UploadPartCopyInput{
+					Bucket:          aws.String(dstBucketName),
+					Key:             aws.String(dstObjectName),
+					CopySource:      aws.String(copySource(srcBucketName, srcObjectName)),
+					CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", first, last)),
+					PartNumber:      aws.Int64(int64(partNumber)),
+					UploadId:        upload.UploadId,
+				})
+
+				lock.Lock()
+				if err != nil {
+					if partErr == nil {
+						partErr = err
+					}
+				} else {
+					parts[partNumber-1] = &s3.CompletedPart{
+						ETag:       output.CopyPartResult.ETag,
+						PartNumber: aws.Int64(int64(partNumber)),
+					}
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if partErr == nil {
+		_, partErr = s3Client.S3.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(dstBucketName),
+			Key:             aws.String(dstObjectName),
+			UploadId:        upload.UploadId,
+			MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+		})
+	}
+	if partErr != nil {
+		logrus.Errorf("multipart copy failed and is aborted, bucket: %s, object name: %s, upload id: %s",
+			dstBucketName, dstObjectName, aws.StringValue(upload.UploadId))
+		_, err = s3Client.S3.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(dstBucketName),
+			Key:      aws.String(dstObjectName),
+			UploadId: upload.UploadId,
+		})
+		if err != nil {
+			logrus.Errorf("abort multipart copy failed, upload id: %s, error: %v", aws.StringValue(upload.UploadId), err)
+		}
+	}
+	return partErr
+}
+
+// copySource is the url encoded x-amz-copy-source of an object.
+func copySource(bucketName, objectName string) string {
+	segments := strings.Split(objectName, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return bucketName + "/" + strings.Join(segments, "/")
+}
+
+func (s3Client *S3Client) GetBucketACL(bucketName string) (*ACL, error) {
+	output, err := s3Client.S3.GetBucketAcl(&s3.GetBucketAclInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s3ACL(output.Owner, output.Grants), nil
+}
+
+func (s3Client *S3Client) PutBucketACL(bucketName string, acl *ACL) error {
+	input := &s3.PutBucketAclInput{
+		Bucket: aws.String(bucketName),
+	}
+	if acl.Canned != "" {
+		input.ACL = aws.String(acl.Canned)
+	} else {
+		input.AccessControlPolicy = s3AccessControlPolicy(acl)
+	}
+	_, err := s3Client.S3.PutBucketAcl(input)
+	return err
+}
+
+func (s3Client *S3Client) GetObjectACL(bucketName, objectName string) (*ACL, error) {
+	output, err := s3Client.S3.GetObjectAcl(&s3.GetObjectAclInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s3ACL(output.Owner, output.Grants), nil
+}
+
+func (s3Client *S3Client) PutObjectACL(bucketName, objectName string, acl *ACL) error {
+	input := &s3.PutObjectAclInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectName),
+	}
+	if acl.Canned != "" {
+		input.ACL = aws.String(acl.Canned)
+	} else {
+		input.AccessControlPolicy = s3AccessControlPolicy(acl)
+	}
+	_, err := s3Client.S3.PutObjectAcl(input)
+	return err
+}
+
+func s3ACL(owner *s3.Owner, grants []*s3.Grant) *ACL {
+	acl := &ACL{}
+	if owner != nil {
+		acl.Owner = aws.StringValue(owner.ID)
+	}
+	for _, grant := range grants {
+		if grant.Grantee == nil {
+			continue
+		}
+		acl.Grants = append(acl.Grants, Grant{
+			GranteeType: aws.StringValue(grant.Grantee.Type),
+			ID:          aws.StringValue(grant.Grantee.ID),
+			Email:       aws.StringValue(grant.Grantee.EmailAddress),
+			URI:         aws.StringValue(grant.Grantee.URI),
+			Permission:  aws.StringValue(grant.Permission),
+		})
+	}
+	return acl
+}
+
+func s3AccessControlPolicy(acl *ACL) *s3.AccessControlPolicy {
+	policy := &s3.AccessControlPolicy{
+		Owner: &s3.Owner{ID: optionalString(acl.Owner)},
+	}
+	for _, grant := range acl.Grants {
+		policy.Grants = append(policy.Grants, &s3.Grant{
+			Grantee: &s3.Grantee{
+				Type:         aws.String(grant.GranteeType),
+				ID:           optionalString(grant.ID),
+				EmailAddress: optionalString(grant.Email),
+				URI:          optionalString(grant.URI),
+			},
+			Permission: aws.String(grant.Permission),
+		})
+	}
+	return policy
+}
+
+func (s3Client *S3Client) GetBucketPolicy(bucketName string) (string, error) {
+	output, err := s3Client.S3.GetBucketPolicy(&s3.GetBucketPolicyInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "NoSuchBucketPolicy" {
+			return "", nil
+		}
+		return "", err
+	}
+	return aws.StringValue(output.Policy), nil
+}
+
+func (s3Client *S3Client) PutBucketPolicy(bucketName, policy string) error {
+	_, err := s3Client.S3.PutBucketPolicy(&s3.PutBucketPolicyInput{
+		Bucket: aws.String(bucketName),
+		Policy: aws.String(policy),
+	})
+	return err
+}
+
+func (s3Client *S3Client) GetBucketCORS(bucketName string) ([]CORSRule, error) {
+	output, err := s3Client.S3.GetBucketCors(&s3.GetBucketCorsInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "NoSuchCORSConfiguration" {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var rules []CORSRule
+	for _, rule := range output.CORSRules {
+		rules = append(rules, CORSRule{
+			AllowedOrigins: aws.StringValueSlice(rule.AllowedOrigins),
+			AllowedMethods: aws.StringValueSlice(rule.AllowedMethods),
+			AllowedHeaders: aws.StringValueSlice(rule.AllowedHeaders),
+			ExposeHeaders:  aws.StringValueSlice(rule.ExposeHeaders),
+			MaxAgeSeconds:  int(aws.Int64Value(rule.MaxAgeSeconds)),
+		})
+	}
+	return rules, nil
+}
+
+func (s3Client *S3Client) PutBucketCORS(bucketName string, rules []CORSRule) error {
+	if len(rules) == 0 {
+		_, err := s3Client.S3.DeleteBucketCors(&s3.DeleteBucketCorsInput{
+			Bucket: aws.String(bucketName),
+		})
+		return err
+	}
+	configuration := &s3.CORSConfiguration{}
+	for _, rule := range rules {
+		s3Rule := &s3.CORSRule{
+			AllowedOrigins: aws.StringSlice(rule.AllowedOrigins),
+			AllowedMethods: aws.StringSlice(rule.AllowedMethods),
+			AllowedHeaders: aws.StringSlice(rule.AllowedHeaders),
+			ExposeHeaders:  aws.StringSlice(rule.ExposeHeaders),
+		}
+		if rule.MaxAgeSeconds > 0 {
+			s3Rule.MaxAgeSeconds = aws.Int64(int64(rule.MaxAgeSeconds))
+		}
+		configuration.CORSRules = append(configuration.CORSRules, s3Rule)
+	}
+	_, err := s3Client.S3.PutBucketCors(&s3.PutBucketCorsInput{
+		Bucket:            aws.String(bucketName),
+		CORSConfiguration: configuration,
+	})
+	return err
+}
+
+func (s3Client *S3Client) GetObjectTagging(bucketName, objectName string) (map[string]string, error) {
+	output, err := s3Client.S3.GetObjectTagging(&s3.GetObjectTaggingInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectName),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string]string)
+	for _, tag := range output.TagSet {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return tags, nil
+}
+
+func (s3Client *S3Client) GetBucketVersioning(bucketName string) (string, error) {
+	output, err := s3Client.S3.GetBucketVersioning(&s3.GetBucketVersioningInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(output.Status), nil
+}
+
+func (s3Client *S3Client) PutBucketVersioning(bucketName, status string) error {
+	_, err := s3Client.S3.PutBucketVersioning(&s3.PutBucketVersioningInput{
+		Bucket: aws.String(bucketName),
+		VersioningConfiguration: &s3.VersioningConfiguration{
+			Status: aws.String(status),
+		},
+	})
+	return err
+}
+
+func (s3Client *S3Client) EnableBucketVersioning(bucketName string) error {
+	err := s3Client.PutBucketVersioning(bucketName, VersioningEnabled)
+	if err != nil {
+		logrus.Errorf("enable bucket versioning failed, bucket: %s, error: %v", bucketName, err)
+		return err
+	}
+	logrus.Infof("enable bucket versioning successful, bucket: %s", bucketName)
+	return nil
+}
+
+// ListObjectVersions lists a page of object versions. The response has the
+// versions and the delete markers in separate lists, they are merged back into
+// the order of the listing.
+func (s3Client *S3Client) ListObjectVersions(bucketName, keyMarker, versionIDMarker, prefix string) (*ListObjectVersionsResult, error) {
+	logrus.Infof("sync bucket: %s, list 1000 object versions...", bucketName)
+	output, err := s3Client.S3.ListObjectVersions(&s3.ListObjectVersionsInput{
+		Bucket:          aws.String(bucketName),
+		KeyMarker:       optionalString(keyMarker),
+		VersionIdMarker: optionalString(versionIDMarker),
+		Prefix:          &prefix,
+	})
+	if err != nil {
+		logrus.Errorf("bucket: %s, list object versions failed, error: %v", bucketName, err)
+		return nil, err
+	}
+
+	var versions []ObjectVersion
+	for _, version := range output.Versions {
+		versions = append(versions, ObjectVersion{
+			ObjectInfo: ObjectInfo{
+				Key:          aws.StringValue(version.Key),
+				Size:         aws.Int64Value(version.Size),
+				ETag:         strings.Trim(aws.StringValue(version.ETag), `"`),
+				LastModified: aws.TimeValue(version.LastModified),
+				StorageClass: aws.StringValue(version.StorageClass),
+			},
+			VersionID: aws.StringValue(version.VersionId),
+			IsLatest:  aws.BoolValue(version.IsLatest),
+		})
+	}
+	for _, marker := range output.DeleteMarkers {
+		versions = append(versions, ObjectVersion{
+			ObjectInfo: ObjectInfo{
+				Key:          aws.StringValue(marker.Key),
+				LastModified: aws.TimeValue(marker.LastModified),
+			},
+			VersionID:    aws.StringValue(marker.VersionId),
+			IsLatest:     aws.BoolValue(marker.IsLatest),
+			DeleteMarker: true,
+		})
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		if versions[i].Key != versions[j].Key {
+			return versions[i].Key < versions[j].Key
+		}
+		if versions[i].IsLatest != versions[j].IsLatest {
+			return versions[i].IsLatest
+		}
+		return versions[i].LastModified.After(versions[j].LastModified)
+	})
+
+	suspend := !aws.BoolValue(output.IsTruncated)
+	if suspend {
+		logrus.Infof("suspend listing object versions in bucket: %s", bucketName)
+		return &ListObjectVersionsResult{Versions: versions, Suspend: &suspend}, nil
+	}
+	return &ListObjectVersionsResult{
+		Versions:            versions,
+		Suspend:             &suspend,
+		NextKeyMarker:       output.NextKeyMarker,
+		NextVersionIDMarker: output.NextVersionIdMarker,
+	}, nil
+}
+
+func (s3Client *S3Client) PutDeleteMarker(bucketName, objectName string) (string, error) {
+	output, err := s3Client.S3.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectName),
+	})
+	if err != nil {
+		logrus.Errorf("put delete marker failed, bucket: %s, object name: %s, error: %v", bucketName, objectName, err)
+		return "", err
+	}
+	if !aws.BoolValue(output.DeleteMarker) {
+		return "", fmt.Errorf("bucket: %s is not versioned, object: %s was deleted instead", bucketName, objectName)
+	}
+	logrus.Infof("put delete marker successful, bucket: %s, object name: %s", bucketName, objectName)
+	return aws.StringValue(output.VersionId), nil
+}
+
+// hasS3ErrorCode reports whether err is an error response with one of codes.
+func hasS3ErrorCode(err error, codes ...string) bool {
+	awsErr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+	for _, code := range codes {
+		if awsErr.Code() == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (s3Client *S3Client) GetBucketLifecycle(bucketName string) ([]LifecycleRule, error) {
+	output, err := s3Client.S3.GetBucketLifecycleConfiguration(&s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		if hasS3ErrorCode(err, "NoSuchLifecycleConfiguration") {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var rules []LifecycleRule
+	for _, s3Rule := range output.Rules {
+		rule := LifecycleRule{
+			ID:      aws.StringValue(s3Rule.ID),
+			Prefix:  aws.StringValue(s3Rule.Prefix),
+			Enabled: aws.StringValue(s3Rule.Status) == s3.ExpirationStatusEnabled,
+		}
+		if filter := s3Rule.Filter; filter != nil {
+			var tags []*s3.Tag
+			if filter.Prefix != nil {
+				rule.Prefix = aws.StringValue(filter.Prefix)
+			}
+			if filter.Tag != nil {
+				tags = append(tags, filter.Tag)
+			}
+			if filter.And != nil {
+				rule.Prefix = aws.StringValue(filter.And.Prefix)
+				tags = append(tags, filter.And.Tags...)
+			}
+			for _, tag := range tags {
+				if rule.Tags == nil {
+					rule.Tags = make(map[string]string)
+				}
+				rule.Tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+		}
+		if expiration := s3Rule.Expiration; expiration != nil {
+			rule.ExpirationDays = int(aws.Int64Value(expiration.Days))
+			rule.ExpirationDate = aws.TimeValue(expiration.Date)
+			rule.ExpiredObjectDeleteMarker = aws.BoolValue(expiration.ExpiredObjectDeleteMarker)
+		}
+		for _, transition := range s3Rule.Transitions {
+			rule.Transitions = append(rule.Transitions, LifecycleTransition{
+				Days:         int(aws.Int64Value(transition.Days)),
+				Date:         aws.TimeValue(transition.Date),
+				StorageClass: aws.StringValue(transition.StorageClass),
+			})
+		}
+		if s3Rule.NoncurrentVersionExpiration != nil {
+			rule.NoncurrentExpirationDays = int(aws.Int64Value(s3Rule.NoncurrentVersionExpiration.NoncurrentDays))
+		}
+		for _, transition := range s3Rule.NoncurrentVersionTransitions {
+			rule.NoncurrentTransitions = append(rule.NoncurrentTransitions, LifecycleTransition{
+				Days:         int(aws.Int64Value(transition.NoncurrentDays)),
+				StorageClass: aws.StringValue(transition.StorageClass),
+			})
+		}
+		if s3Rule.AbortIncompleteMultipartUpload != nil {
+			rule.AbortMultipartUploadDays = int(aws.Int64Value(s3Rule.AbortIncompleteMultipartUpload.DaysAfterInitiation))
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (s3Client *S3Client) PutBucketLifecycle(bucketName string, rules []LifecycleRule) error {
+	if len(rules) == 0 {
+		_, err := s3Client.S3.DeleteBucketLifecycle(&s3.DeleteBucketLifecycleInput{
+			Bucket: aws.String(bucketName),
+		})
+		return err
+	}
+
+	configuration := &s3.BucketLifecycleConfiguration{}
+	for _, rule := range rules {
+		s3Rule := &s3.LifecycleRule{
+			ID:     optionalString(rule.ID),
+			Status: aws.String(s3.ExpirationStatusDisabled),
+			Filter: s3LifecycleFilter(rule.Prefix, rule.Tags),
+		}
+		if rule.Enabled {
+			s3Rule.Status = aws.String(s3.ExpirationStatusEnabled)
+		}
+		if rule.ExpirationDays > 0 || !rule.ExpirationDate.IsZero() || rule.ExpiredObjectDeleteMarker {
+			s3Rule.Expiration = &s3.LifecycleExpiration{
+				Days: optionalInt64(rule.ExpirationDays),
+				Date: optionalTime(rule.ExpirationDate),
+			}
+			if rule.ExpiredObjectDeleteMarker {
+				s3Rule.Expiration.ExpiredObjectDeleteMarker = aws.Bool(true)
+			}
+		}
+		for _, transition := range rule.Transitions {
+			s3Rule.Transitions = append(s3Rule.Transitions, &s3.Transition{
+				Days:         optionalInt64(transition.Days),
+				Date:         optionalTime(transition.Date),
+				StorageClass: aws.String(transition.StorageClass),
+			})
+		}
+		if rule.NoncurrentExpirationDays > 0 {
+			s3Rule.NoncurrentVersionExpiration = &s3.NoncurrentVersionExpiration{
+				NoncurrentDays: aws.Int64(int64(rule.NoncurrentExpirationDays)),
+			}
+		}
+		for _, transition := range rule.NoncurrentTransitions {
+			s3Rule.NoncurrentVersionTransitions = append(s3Rule.NoncurrentVersionTransitions, &s3.NoncurrentVersionTransition{
+				NoncurrentDays: aws.Int64(int64(transition.Days)),
+				StorageClass:   aws.String(transition.StorageClass),
+			})
+		}
+		if rule.AbortMultipartUploadDays > 0 {
+			s3Rule.AbortIncompleteMultipartUpload = &s3.AbortIncompleteMultipartUpload{
+				DaysAfterInitiation: aws.Int64(int64(rule.AbortMultipartUploadDays)),
+			}
+		}
+		configuration.Rules = append(configuration.Rules, s3Rule)
+	}
+	_, err := s3Client.S3.PutBucketLifecycleConfiguration(&s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 aws.String(bucketName),
+		LifecycleConfiguration: configuration,
+	})
+	return err
+}
+
+// s3LifecycleFilter returns the filter of a lifecycle rule, tags and a prefix
+// are combined with And.
+func s3LifecycleFilter(prefix string, tags map[string]string) *s3.LifecycleRuleFilter {
+	s3Tags := s3TagSet(tags)
+	switch {
+	case len(s3Tags) == 0:
+		return &s3.LifecycleRuleFilter{Prefix: aws.String(prefix)}
+	case len(s3Tags) == 1 && prefix == "":
+		return &s3.LifecycleRuleFilter{Tag: s3Tags[0]}
+	default:
+		return &s3.LifecycleRuleFilter{And: &s3.LifecycleRuleAndOperator{
+			Prefix: optionalString(prefix),
+			Tags:   s3Tags,
+		}}
+	}
+}
+
+// s3TagSet returns tags sorted by key.
+func s3TagSet(tags map[string]string) []*s3.Tag {
+	var tagSet []*s3.Tag
+	for key, value := range tags {
+		tagSet = append(tagSet, &s3.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	sort.Slice(tagSet, func(i, j int) bool {
+		return aws.StringValue(tagSet[i].Key) < aws.StringValue(tagSet[j].Key)
+	})
+	return tagSet
+}
+
+// optionalInt64 returns nil for 0, so the element is not sent.
+func optionalInt64(value int) *int64 {
+	if value == 0 {
+		return nil
+	}
+	return aws.Int64(int64(value))
+}
+
+// optionalTime returns nil for the zero time, so the element is not sent.
+func optionalTime(value time.Time) *time.Time {
+	if value.IsZero() {
+		return nil
+	}
+	return aws.Time(value)
+}
+
+func (s3Client *S3Client) GetBucketTagging(bucketName string) (map[string]string, error) {
+	output, err := s3Client.S3.GetBucketTagging(&s3.GetBucketTaggingInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		if hasS3ErrorCode(err, "NoSuchTagSet", "NoSuchTagSetError") {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	tags := make(map[string]string)
+	for _, tag := range output.TagSet {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return tags, nil
+}
+
+func (s3Client *S3Client) PutBucketTagging(bucketName string, tags map[string]string) error {
+	if len(tags) == 0 {
+		_, err := s3Client.S3.DeleteBucketTagging(&s3.DeleteBucketTaggingInput{
+			Bucket: aws.String(bucketName),
+		})
+		return err
+	}
+	_, err := s3Client.S3.PutBucketTagging(&s3.PutBucketTaggingInput{
+		Bucket:  aws.String(bucketName),
+		Tagging: &s3.Tagging{TagSet: s3TagSet(tags)},
+	})
+	return err
+}
+
+// GetBucketWebsite returns the index and error documents of the website of a
+// bucket, redirects and routing rules are not supported.
+func (s3Client *S3Client) GetBucketWebsite(bucketName string) (*WebsiteConfig, error) {
+	output, err := s3Client.S3.GetBucketWebsite(&s3.GetBucketWebsiteInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		if hasS3ErrorCode(err, "NoSuchWebsiteConfiguration") {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if output.RedirectAllRequestsTo != nil || len(output.RoutingRules) > 0 {
+		logrus.Warnf("website redirects and routing rules are not supported and dropped, bucket: %s", bucketName)
+	}
+	website := &WebsiteConfig{}
+	if output.IndexDocument != nil {
+		website.IndexDocument = aws.StringValue(output.IndexDocument.Suffix)
+	}
+	if output.ErrorDocument != nil {
+		website.ErrorDocument = aws.StringValue(output.ErrorDocument.Key)
+	}
+	return website, nil
+}
+
+func (s3Client *S3Client) PutBucketWebsite(bucketName string, website *WebsiteConfig) error {
+	if website == nil {
+		_, err := s3Client.S3.DeleteBucketWebsite(&s3.DeleteBucketWebsiteInput{
+			Bucket: aws.String(bucketName),
+		})
+		return err
+	}
+
+	configuration := &s3.WebsiteConfiguration{}
+	if website.IndexDocument != "" {
+		configuration.IndexDocument = &s3.IndexDocument{Suffix: aws.String(website.IndexDocument)}
+	}
+	if website.ErrorDocument != "" {
+		configuration.ErrorDocument = &s3.ErrorDocument{Key: aws.String(website.ErrorDocument)}
+	}
+	_, err := s3Client.S3.PutBucketWebsite(&s3.PutBucketWebsiteInput{
+		Bucket:               aws.String(bucketName),
+		WebsiteConfiguration: configuration,
+	})
+	return err
+}
+
+func (s3Client *S3Client) GetBucketEncryption(bucketName string) (*BucketEncryption, error) {
+	output, err := s3Client.S3.GetBucketEncryption(&s3.GetBucketEncryptionInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		if hasS3ErrorCode(err, "ServerSideEncryptionConfigurationNotFoundError") {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if output.ServerSideEncryptionConfiguration == nil {
+		return nil, nil
+	}
+	for _, rule := range output.ServerSideEncryptionConfiguration.Rules {
+		if rule.ApplyServerSideEncryptionByDefault != nil {
+			return &BucketEncryption{
+				Algorithm: aws.StringValue(rule.ApplyServerSideEncryptionByDefault.SSEAlgorithm),
+				KMSKeyID:  aws.StringValue(rule.ApplyServerSideEncryptionByDefault.KMSMasterKeyID),
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s3Client *S3Client) PutBucketEncryption(bucketName string, encryption *BucketEncryption) error {
+	if encryption == nil {
+		_, err := s3Client.S3.DeleteBucketEncryption(&s3.DeleteBucketEncryptionInput{
+			Bucket: aws.String(bucketName),
+		})
+		return err
+	}
+	_, err := s3Client.S3.PutBucketEncryption(&s3.PutBucketEncryptionInput{
+		Bucket: aws.String(bucketName),
+		ServerSideEncryptionConfiguration: &s3.ServerSideEncryptionConfiguration{
+			Rules: []*s3.ServerSideEncryptionRule{{
+				ApplyServerSideEncryptionByDefault: &s3.ServerSideEncryptionByDefault{
+					SSEAlgorithm:   aws.String(encryption.Algorithm),
+					KMSMasterKeyID: optionalString(encryption.KMSKeyID),
+				},
+			}},
+		},
+	})
+	return err
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3Request is a request received by the fake s3 server.
+type fakeS3Request struct {
+	method        string
+	query         string
+	contentSha256 string
+	body          []byte
+}
+
+func TestS3UploadFileSmallObject(t *testing.T) {
+	const content = "content of a small object"
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(content))
+	}))
+	defer source.Close()
+
+	var lock sync.Mutex
+	var requests []fakeS3Request
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		lock.Lock()
+		defer lock.Unlock()
+		requests = append(requests, fakeS3Request{
+			method:        r.Method,
+			query:         r.URL.RawQuery,
+			contentSha256: r.Header.Get("X-Amz-Content-Sha256"),
+			body:          body,
+		})
+		w.Header().Set("x-amz-version-id", "version-1")
+	}))
+	defer target.Close()
+
+	fileName := filepath.Join(t.TempDir(), "object")
+	if err := ioutil.WriteFile(fileName, []byte(content), os.ModePerm); err != nil {
+		t.Fatalf("write file failed, error: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		urlType       UrlType
+		urlStr        string
+		unsignedBody  bool
+		wantVersionID string
+	}{
+		{"http body", HttpUrl, source.URL + "/object", true, "version-1"},
+		{"file body", LocalUrl, fileName, false, "version-1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests = nil
+			client, err := NewS3Client(&S3Config{
+				AccessKey: "test-access-key",
+				SecretKey: "test-secret-key",
+				EndPoint:  target.URL,
+			})
+			if err != nil {
+				t.Fatalf("new s3 client failed, error: %v", err)
+			}
+
+			versionID, err := client.UploadFileVersion(test.urlType, test.urlStr, "test-bucket", "object", nil)
+			if err != nil {
+				t.Fatalf("upload failed, error: %v", err)
+			}
+			if versionID != test.wantVersionID {
+				t.Errorf("version id = %q, want %q", versionID, test.wantVersionID)
+			}
+			if len(requests) != 1 {
+				t.Fatalf("sent %d requests, want a single PutObject", len(requests))
+			}
+			request := requests[0]
+			if request.method != http.MethodPut || request.query != "" || !bytes.Equal(request.body, []byte(content)) {
+				t.Errorf("sent %s ?%s with %q, want PUT of %q", request.method, request.query, request.body, content)
+			}
+			if unsigned := request.contentSha256 == "UNSIGNED-PAYLOAD"; unsigned != test.unsignedBody {
+				t.Errorf("x-amz-content-sha256 = %q, want unsigned payload: %v", request.contentSha256, test.unsignedBody)
+			}
+		})
+	}
+}
+
+func TestNewS3ClientMultipartThreshold(t *testing.T) {
+	const mb = 1024 * 1024
+	tests := []struct {
+		multipart MultipartConfig
+		wantErr   bool
+	}{
+		{MultipartConfig{}, false},
+		{MultipartConfig{PartSize: 16 * mb, Threshold: 16 * mb}, false},
+		{MultipartConfig{PartSize: 16 * mb, Threshold: 64 * mb}, false},
+		{MultipartConfig{PartSize: 128 * mb}, true},
+		{MultipartConfig{PartSize: 16 * mb, Threshold: 8 * mb}, true},
+		{MultipartConfig{Threshold: 1 * mb}, true},
+	}
+
+	for _, test := range tests {
+		_, err := NewS3Client(&S3Config{EndPoint: "http://127.0.0.1:9000", Multipart: test.multipart})
+		if (err != nil) != test.wantErr {
+			t.Errorf("multipart %+v error = %v, want error: %v", test.multipart, err, test.wantErr)
+		}
+		if err != nil && !strings.Contains(err.Error(), "part size") {
+			t.Errorf("multipart %+v error = %v, want an error about the part size", test.multipart, err)
+		}
+	}
+}
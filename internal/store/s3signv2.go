@@ -0,0 +1,176 @@
+package store
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// signV2SubResources are the query parameters that are part of the resource
+// signed by s3 signature version 2.
+var signV2SubResources = map[string]struct{}{
+	"acl": {}, "cors": {}, "delete": {}, "lifecycle": {}, "location": {}, "logging": {},
+	"notification": {}, "partNumber": {}, "policy": {}, "requestPayment": {}, "tagging": {},
+	"torrent": {}, "uploadId": {}, "uploads": {}, "versionId": {}, "versioning": {},
+	"versions": {}, "website": {}, "encryption": {}, "restore": {},
+	"response-cache-control": {}, "response-content-disposition": {},
+	"response-content-encoding": {}, "response-content-language": {},
+	"response-content-type": {}, "response-expires": {},
+}
+
+// useSignatureV2 replaces the signature version 4 signer of client with the
+// legacy s3 signature version 2, which older RGW and other s3 compatible stores
+// still require. Presigned requests are signed in the query string.
+func useSignatureV2(client *s3.S3, credential *credentials.Credentials, pathStyle bool) {
+	client.Handlers.Sign.Clear()
+	client.Handlers.Sign.PushBack(func(req *request.Request) {
+		signV2(req, credential, pathStyle)
+	})
+}
+
+func signV2(req *request.Request, credential *credentials.Credentials, pathStyle bool) {
+	value, err := credential.Get()
+	if err != nil {
+		req.Error = err
+		return
+	}
+	if value.AccessKeyID == "" && value.SecretAccessKey == "" {
+		// anonymous request
+		return
+	}
+
+	httpReq := req.HTTPRequest
+	if value.SessionToken != "" {
+		httpReq.Header.Set("X-Amz-Security-Token", value.SessionToken)
+	}
+
+	presign := req.ExpireTime > 0
+	date := time.Now().UTC()
+	dateValue := date.Format(http.TimeFormat)
+	if presign {
+		dateValue = strconv.FormatInt(date.Add(req.ExpireTime).Unix(), 10)
+	} else {
+		httpReq.Header.Set("Date", dateValue)
+	}
+
+	stringToSign := strings.Join([]string{
+		httpReq.Method,
+		httpReq.Header.Get("Content-MD5"),
+		httpReq.Header.Get("Content-Type"),
+		dateValue,
+		canonicalAmzHeaders(httpReq.Header) + canonicalResourceV2(httpReq, requestBucket(req, pathStyle)),
+	}, "\n")
+
+	mac := hmac.New(sha1.New, []byte(value.SecretAccessKey))
+	mac.Write([]byte(stringToSign))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	if presign {
+		// a presigned url is fetched without the headers of the request, so
+		// the session token has to travel in the query string
+		query := httpReq.URL.Query()
+		if value.SessionToken != "" {
+			httpReq.Header.Del("X-Amz-Security-Token")
+			query.Set("x-amz-security-token", value.SessionToken)
+		}
+		query.Set("AWSAccessKeyId", value.AccessKeyID)
+		query.Set("Expires", dateValue)
+		query.Set("Signature", signature)
+		httpReq.URL.RawQuery = query.Encode()
+		return
+	}
+	httpReq.Header.Set("Authorization", "AWS "+value.AccessKeyID+":"+signature)
+}
+
+func canonicalAmzHeaders(header http.Header) string {
+	var names []string
+	for name := range header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") {
+			names = append(names, lower)
+		}
+	}
+	sort.Strings(names)
+
+	var builder strings.Builder
+	for _, name := range names {
+		builder.WriteString(name)
+		builder.WriteString(":")
+		builder.WriteString(strings.Join(header.Values(name), ","))
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// requestBucket returns the bucket of req when it is addressed in the host name
+// rather than the url path. The bucket is taken from the request parameters, as
+// bucket names may contain dots themselves.
+func requestBucket(req *request.Request, pathStyle bool) string {
+	if pathStyle {
+		return ""
+	}
+	values, err := awsutil.ValuesAtPath(req.Params, "Bucket")
+	if err != nil || len(values) == 0 {
+		return ""
+	}
+	bucketName, ok := values[0].(*string)
+	if !ok || bucketName == nil {
+		return ""
+	}
+	// the sdk falls back to path style for buckets that are not valid host
+	// names
+	if !strings.HasPrefix(req.HTTPRequest.URL.Host, *bucketName+".") {
+		return ""
+	}
+	return *bucketName
+}
+
+// canonicalResourceV2 is the bucket and key of the request followed by its sub
+// resources. bucketName is only given when the bucket is addressed in the host
+// name.
+func canonicalResourceV2(httpReq *http.Request, bucketName string) string {
+	resource := httpReq.URL.EscapedPath()
+	if resource == "" {
+		resource = "/"
+	}
+	if bucketName != "" {
+		resource = "/" + bucketName + resource
+	}
+
+	query := httpReq.URL.Query()
+	var names []string
+	for name := range query {
+		if _, ok := signV2SubResources[name]; ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return resource
+	}
+	sort.Strings(names)
+
+	var subResources []string
+	for _, name := range names {
+		value := query.Get(name)
+		if value == "" {
+			subResources = append(subResources, name)
+			continue
+		}
+		unescaped, err := url.QueryUnescape(value)
+		if err == nil {
+			value = unescaped
+		}
+		subResources = append(subResources, name+"="+value)
+	}
+	return resource + "?" + strings.Join(subResources, "&")
+}
@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	testAccessKey = "test-access-key"
+	testSecretKey = "test-secret-key"
+)
+
+func testSignatureV2(stringToSign string) string {
+	mac := hmac.New(sha1.New, []byte(testSecretKey))
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// newSignV2TestClient returns a client signing with signature version 2 whose
+// requests all reach server, whatever host name they are addressed to.
+func newSignV2TestClient(t *testing.T, server *httptest.Server, addressingStyle, sessionToken string) *S3Client {
+	client, err := NewS3Client(&S3Config{
+		AccessKey:        testAccessKey,
+		SecretKey:        testSecretKey,
+		SessionToken:     sessionToken,
+		EndPoint:         server.URL,
+		AddressingStyle:  addressingStyle,
+		SignatureVersion: SignatureV2,
+	})
+	if err != nil {
+		t.Fatalf("new s3 client failed, error: %v", err)
+	}
+	client.S3.Config.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+			},
+		},
+	}
+	return client
+}
+
+func TestSignV2Header(t *testing.T) {
+	tests := []struct {
+		name            string
+		addressingStyle string
+		bucketName      string
+		sessionToken    string
+		wantHost        string
+		wantResource    string
+	}{
+		{"path style", PathStyle, "my-bucket", "", "", "/my-bucket/dir/a.txt"},
+		{"virtual style", VirtualStyle, "my-bucket", "", "my-bucket.", "/my-bucket/dir/a.txt"},
+		{"virtual style dotted bucket", VirtualStyle, "my.dotted.bucket", "", "my.dotted.bucket.", "/my.dotted.bucket/dir/a.txt"},
+		{"session token", PathStyle, "my-bucket", "session-token", "", "/my-bucket/dir/a.txt"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got *http.Request
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r
+			}))
+			defer server.Close()
+
+			client := newSignV2TestClient(t, server, test.addressingStyle, test.sessionToken)
+			_, err := client.S3.HeadObject(&s3.HeadObjectInput{
+				Bucket: aws.String(test.bucketName),
+				Key:    aws.String("dir/a.txt"),
+			})
+			if err != nil {
+				t.Fatalf("head object failed, error: %v", err)
+			}
+
+			if !strings.HasPrefix(got.Host, test.wantHost) {
+				t.Errorf("host = %q, want prefix %q", got.Host, test.wantHost)
+			}
+			amzHeaders := ""
+			if test.sessionToken != "" {
+				amzHeaders = "x-amz-security-token:" + test.sessionToken + "\n"
+			}
+			stringToSign := "HEAD\n\n\n" + got.Header.Get("Date") + "\n" + amzHeaders + test.wantResource
+			want := "AWS " + testAccessKey + ":" + testSignatureV2(stringToSign)
+			if auth := got.Header.Get("Authorization"); auth != want {
+				t.Errorf("authorization = %q, want %q", auth, want)
+			}
+		})
+	}
+}
+
+func TestSignV2Presign(t *testing.T) {
+	tests := []struct {
+		name         string
+		sessionToken string
+	}{
+		{"no session token", ""},
+		{"session token", "session-token"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				query := r.URL.Query()
+				amzHeaders := ""
+				if test.sessionToken != "" {
+					if token := query.Get("x-amz-security-token"); token != test.sessionToken {
+						t.Errorf("x-amz-security-token = %q, want %q", token, test.sessionToken)
+					}
+					amzHeaders = "x-amz-security-token:" + test.sessionToken + "\n"
+				}
+				stringToSign := "GET\n\n\n" + query.Get("Expires") + "\n" + amzHeaders + "/my-bucket/a.txt"
+				if query.Get("AWSAccessKeyId") != testAccessKey || query.Get("Signature") != testSignatureV2(stringToSign) {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				w.Write([]byte("content"))
+			}))
+			defer server.Close()
+
+			client := newSignV2TestClient(t, server, PathStyle, test.sessionToken)
+			objectUrl, urlType, err := client.GetObjectUrl("my-bucket", "a.txt")
+			if err != nil {
+				t.Fatalf("get object url failed, error: %v", err)
+			}
+			body, _, err := OpenUrlData(urlType, objectUrl)
+			if err != nil {
+				t.Fatalf("open object url failed, url: %s, error: %v", objectUrl, err)
+			}
+			defer body.Close()
+			content, err := ioutil.ReadAll(body)
+			if err != nil || string(content) != "content" {
+				t.Errorf("content = %q, error: %v, want %q", content, err, "content")
+			}
+		})
+	}
+}
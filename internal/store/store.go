@@ -1,23 +1,80 @@
 package store
 
 import (
+	"errors"
 	"github.com/sirupsen/logrus"
 	"io"
-	"io/ioutil"
 	"net/http"
+	"os"
 	"strings"
+	"time"
 )
 
 type ListBucketsResult struct {
 	BucketNames []string
 }
 
+// ObjectInfo describes an object as reported by a listing or a HEAD request.
+// ETag is unquoted and empty when the store has none, as for local files.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ETag         string
+	LastModified time.Time
+	// StorageClass is empty for the default storage class of the store
+	StorageClass string
+	// Meta is only set by StatObject
+	Meta *ObjectMeta
+}
+
+// ObjectMeta is the metadata of an object that is carried over to its copies.
+// UserMeta keys are lower case and have no x-amz-meta- or x-oss-meta- prefix,
+// so user metadata translates between S3 and OSS.
+type ObjectMeta struct {
+	ContentType        string
+	ContentEncoding    string
+	ContentDisposition string
+	ContentLanguage    string
+	CacheControl       string
+	Expires            string
+	UserMeta           map[string]string
+}
+
+// expiresTime returns the parsed Expires header, nil when it is not a valid
+// http date.
+func (meta *ObjectMeta) expiresTime() *time.Time {
+	expires, err := http.ParseTime(meta.Expires)
+	if err != nil {
+		return nil
+	}
+	return &expires
+}
+
+// UploadOptions are applied to the objects written by UploadFile or CopyObject,
+// a nil UploadOptions keeps the defaults of the target store.
+type UploadOptions struct {
+	// Meta replaces the metadata of the object when set
+	Meta *ObjectMeta
+	// Tags replace the tags of the object when not nil
+	Tags map[string]string
+	// StorageClass is the storage class the object is written to when set
+	StorageClass string
+}
+
+// TaggingStore is implemented by stores with object tags.
+type TaggingStore interface {
+	GetObjectTagging(bucketName, objectName string) (map[string]string, error)
+}
+
 type ListObjectsResult struct {
-	ObjectsName []string
-	Suspend     *bool
-	NextMarker  *string
+	Objects    []ObjectInfo
+	Suspend    *bool
+	NextMarker *string
 }
 
+// ErrObjectNotFound is returned by StatObject when the object does not exist.
+var ErrObjectNotFound = errors.New("object not found")
+
 type UrlType string
 
 const (
@@ -29,40 +86,65 @@ type Store interface {
 	ListBuckets() (*ListBucketsResult, error)
 	CheckBucketExist(bucketName string) (bool, error)
 	CreateBucket(bucketName string) error
-	UploadFile(urlType UrlType, urlStr, dstBucketName, dstObjectName string) error
+	UploadFile(urlType UrlType, urlStr, dstBucketName, dstObjectName string, opts *UploadOptions) error
 	GetObjectUrl(bucketName, objectName string) (string, UrlType, error)
 	ListObjects(bucketName, marker, prefix string) (*ListObjectsResult, error)
+	StatObject(bucketName, objectName string) (*ObjectInfo, error)
+	DeleteObject(bucketName, objectName string) error
+}
+
+// ServerSideCopier is implemented by stores that copy objects within themselves,
+// so the data of an object never leaves the store.
+type ServerSideCopier interface {
+	// CanCopyFrom reports whether source reaches the same store with the same
+	// credentials, so objects of source can be copied server side.
+	CanCopyFrom(source Store) bool
+	// CopyObject copies an object of size bytes within the store.
+	CopyObject(srcBucketName, srcObjectName, dstBucketName, dstObjectName string, size int64, opts *UploadOptions) error
 }
 
-func ReadUrlData(urlType UrlType, urlStr string) ([]byte, error) {
+// OpenUrlData opens the object behind urlStr for streaming. The returned size is
+// the object length in bytes, or -1 when the source does not report it.
+// Callers must close the returned reader.
+func OpenUrlData(urlType UrlType, urlStr string) (io.ReadCloser, int64, error) {
 	if urlType == HttpUrl {
-		return readHttpUrl(urlStr)
+		return openHttpUrl(urlStr)
 	} else {
-		return readLocalUrl(urlStr)
+		return openLocalUrl(urlStr)
 	}
 }
 
-func readHttpUrl(urlStr string) ([]byte, error) {
+func openHttpUrl(urlStr string) (io.ReadCloser, int64, error) {
 	resp, err := http.Get(strings.TrimSpace(urlStr))
 	if err != nil {
-		return nil, err
+		return nil, -1, err
 	}
 
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			logrus.Errorf("close http body error: %v", err)
-		}
-	}(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		closeBody(resp.Body)
+		return nil, -1, &HttpStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
 
-	data, err := ioutil.ReadAll(resp.Body)
+	return resp.Body, resp.ContentLength, nil
+}
+
+func openLocalUrl(urlStr string) (io.ReadCloser, int64, error) {
+	file, err := os.Open(urlStr)
 	if err != nil {
-		return nil, err
+		return nil, -1, err
 	}
 
-	return data, nil
+	info, err := file.Stat()
+	if err != nil {
+		closeBody(file)
+		return nil, -1, err
+	}
+	return file, info.Size(), nil
 }
 
-func readLocalUrl(urlStr string) ([]byte, error) {
-	return ioutil.ReadFile(urlStr)
+func closeBody(body io.Closer) {
+	err := body.Close()
+	if err != nil {
+		logrus.Errorf("close object body error: %v", err)
+	}
 }
@@ -0,0 +1,41 @@
+package store
+
+const (
+	VersioningEnabled   = "Enabled"
+	VersioningSuspended = "Suspended"
+)
+
+// ObjectVersion is a version of an object, or a delete marker when DeleteMarker
+// is set. Delete markers have no size, ETag or storage class.
+type ObjectVersion struct {
+	ObjectInfo
+	VersionID    string
+	IsLatest     bool
+	DeleteMarker bool
+}
+
+// ListObjectVersionsResult is a page of object versions and delete markers,
+// sorted by key and from the newest to the oldest version of every key.
+type ListObjectVersionsResult struct {
+	Versions            []ObjectVersion
+	Suspend             *bool
+	NextKeyMarker       *string
+	NextVersionIDMarker *string
+}
+
+// VersionedStore is implemented by stores with versioned buckets.
+type VersionedStore interface {
+	// GetBucketVersioning returns the versioning status of a bucket, empty
+	// when versioning has never been enabled.
+	GetBucketVersioning(bucketName string) (string, error)
+	EnableBucketVersioning(bucketName string) error
+	ListObjectVersions(bucketName, keyMarker, versionIDMarker, prefix string) (*ListObjectVersionsResult, error)
+	GetObjectVersionUrl(bucketName, objectName, versionID string) (string, UrlType, error)
+	StatObjectVersion(bucketName, objectName, versionID string) (*ObjectInfo, error)
+	// UploadFileVersion is UploadFile for a versioned bucket, it returns the
+	// version ID of the new version.
+	UploadFileVersion(urlType UrlType, urlStr, dstBucketName, dstObjectName string, opts *UploadOptions) (string, error)
+	// PutDeleteMarker makes a new delete marker the current version of an
+	// object and returns its version ID.
+	PutDeleteMarker(bucketName, objectName string) (string, error)
+}